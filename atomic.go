@@ -0,0 +1,117 @@
+package gopar3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PartialSuffix marks output files that are still being written
+// or whose writing was interrupted.
+const PartialSuffix = ".partial"
+
+// ErrDestinationExists is returned instead of replacing a finished
+// output file. See [CreateAtomicFile], [OpenAtomicFile],
+// and [WithOverwrite].
+var ErrDestinationExists = errors.New("destination file already exists")
+
+// AtomicFile is written under a partial name next to its destination
+// and takes the destination name only when finished without error,
+// so an interrupted operation never leaves a file that looks complete.
+type AtomicFile struct {
+	*os.File
+	destination string
+	// resumable files are kept when interrupted
+	resumable bool
+}
+
+// CreateAtomicFile opens a partial file for writing. An existing
+// partial file of the same destination is truncated. The partial
+// file is removed if the operation is interrupted. Unless asked
+// to overwrite, it returns [ErrDestinationExists] for a destination
+// that was already finished.
+func CreateAtomicFile(destination string, overwrite bool) (*AtomicFile, error) {
+	if err := checkDestination(destination, overwrite); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(destination+PartialSuffix, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: f, destination: destination}, nil
+}
+
+// OpenAtomicFile opens a partial file for writing, keeping
+// the contents left by an interrupted operation. The partial
+// file is kept if the operation is interrupted again. Unless
+// asked to overwrite, it returns [ErrDestinationExists] for
+// a destination that was already finished.
+func OpenAtomicFile(destination string, overwrite bool) (*AtomicFile, error) {
+	if err := checkDestination(destination, overwrite); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(destination+PartialSuffix, os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: f, destination: destination, resumable: true}, nil
+}
+
+// checkDestination returns [ErrDestinationExists] for
+// an existing destination, unless it may be overwritten.
+func checkDestination(destination string, overwrite bool) error {
+	if overwrite {
+		return nil
+	}
+	if _, err := os.Stat(destination); err == nil {
+		return fmt.Errorf("%w: %s", ErrDestinationExists, destination)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Destination returns the name the file takes when finished.
+func (f *AtomicFile) Destination() string {
+	return f.destination
+}
+
+// Finish closes the file. If the operation succeeded, the file is
+// synced to disk and renamed to its destination. A file opened by
+// [OpenAtomicFile] and interrupted by context cancellation is kept
+// under the partial name, so that it can be resumed. Otherwise, the
+// partial file is removed. Returns the cause joined with any error
+// of finishing.
+func (f *AtomicFile) Finish(cause error) error {
+	if cause != nil {
+		err := f.Close()
+		if f.resumable && errors.Is(cause, context.Canceled) {
+			return errors.Join(cause, err)
+		}
+		return errors.Join(cause, err, os.Remove(f.Name()))
+	}
+	if err := f.Sync(); err != nil {
+		return errors.Join(err, f.Close(), os.Remove(f.Name()))
+	}
+	if err := f.Close(); err != nil {
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+	if err := os.Rename(f.Name(), f.destination); err != nil {
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+	syncDirectory(filepath.Dir(f.destination))
+	return nil
+}
+
+// syncDirectory persists the rename. Some platforms cannot sync
+// directories, which is why errors are ignored.
+func syncDirectory(path string) {
+	d, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
@@ -0,0 +1,107 @@
+package gopar3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicFile(t *testing.T) {
+	failure := errors.New("failure")
+	cases := []struct {
+		cause       error
+		resumable   bool
+		destination bool
+		partial     bool
+	}{
+		{cause: nil, destination: true},
+		{cause: context.Canceled},
+		{cause: context.Canceled, resumable: true, partial: true},
+		{cause: fmt.Errorf("wrapped: %w", context.Canceled), resumable: true, partial: true},
+		{cause: failure},
+		{cause: failure, resumable: true},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%v resumable %v", c.cause, c.resumable), func(t *testing.T) {
+			destination := filepath.Join(t.TempDir(), "output")
+			open := CreateAtomicFile
+			if c.resumable {
+				open = OpenAtomicFile
+			}
+			f, err := open(destination, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = f.WriteString("content"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = os.Stat(destination); !errors.Is(err, os.ErrNotExist) {
+				t.Fatal("destination appeared before the file was finished")
+			}
+			if err = f.Finish(c.cause); !errors.Is(err, c.cause) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err = os.Stat(destination); (err == nil) != c.destination {
+				t.Errorf("destination exists: %v, expected: %v", err == nil, c.destination)
+			}
+			if _, err = os.Stat(destination + PartialSuffix); (err == nil) != c.partial {
+				t.Errorf("partial file exists: %v, expected: %v", err == nil, c.partial)
+			}
+		})
+	}
+
+	destination := filepath.Join(t.TempDir(), "output")
+	if err := os.WriteFile(destination, []byte("finished"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, open := range [...]func(string, bool) (*AtomicFile, error){CreateAtomicFile, OpenAtomicFile} {
+		if _, err := open(destination, false); !errors.Is(err, ErrDestinationExists) {
+			t.Fatalf("expected existing destination to be refused, got: %v", err)
+		}
+		f, err := open(destination, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = f.Finish(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInflateLeavesNoPartialFiles(t *testing.T) {
+	destination := t.TempDir()
+	if err := Inflate(context.Background(), destination, "README.md", 3, 2, 32); err != nil {
+		t.Fatal(err)
+	}
+	partial, err := filepath.Glob(filepath.Join(destination, "*"+PartialSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partial) > 0 {
+		t.Fatalf("partial files were left behind: %v", partial)
+	}
+	outputs, err := filepath.Glob(filepath.Join(destination, "*.gopar3"))
+	if err != nil || len(outputs) != 1 {
+		t.Fatalf("expected one output, got %v: %v", outputs, err)
+	}
+	if err = Inflate(context.Background(), destination, "README.md", 3, 2, 32); !errors.Is(err, ErrDestinationExists) {
+		t.Fatalf("expected existing destination to be refused, got: %v", err)
+	}
+	if err = Inflate(context.Background(), destination, "README.md", 3, 2, 32, WithOverwrite()); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	output := filepath.Join(destination, "cancelled.gopar3")
+	if err = Inflate(cancelled, output, "README.md", 3, 2, 32); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got: %v", err)
+	}
+	if _, err = os.Stat(output); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("cancelled inflate produced an output that looks complete")
+	}
+}
@@ -2,6 +2,8 @@ package gopar3
 
 import (
 	"io"
+
+	"github.com/dkotik/gopar3/swap"
 )
 
 // PaddingByte is a shard filler for making them equal size.
@@ -14,6 +16,9 @@ type BatchLoader struct {
 	Quorum    int
 	Shards    int
 	ShardSize int
+	// Pool provides shard buffers, if set. Parity shards
+	// are then empty buffers for the encoder to fill.
+	Pool *swap.Pool
 }
 
 // Seek repositions the cursor at the expected boundary of the
@@ -24,21 +29,39 @@ func (g *BatchLoader) Seek(r io.Seeker, batch int) (n int64, err error) {
 }
 
 // Load returns a batch of padded shards and the the number
-// of data bytes that were read. The parity
-// shards are `nil`. The final batch returns io.EOF as error.
+// of data bytes that were read. The parity shards are `nil`
+// or empty pool buffers. The final batch returns io.EOF as error.
 func (g *BatchLoader) Load(r io.Reader) (batch [][]byte, loaded int, err error) {
 	batch = make([][]byte, g.Shards)
 	var (
-		n int
-		i int
-		j int
+		n     int
+		i     int
+		j     int
+		shard []byte
 	)
+	if g.Pool != nil {
+		for i = g.Quorum; i < g.Shards; i++ {
+			if shard, err = g.Pool.Get(); err != nil {
+				return nil, loaded, err
+			}
+			batch[i] = shard[:0]
+		}
+	}
 
 	for i = range batch[:g.Quorum] {
-		shard := make([]byte, g.ShardSize)
+		if shard, err = g.allocate(); err != nil {
+			g.Release(batch)
+			return nil, loaded, err
+		}
 		n, err = io.ReadFull(r, shard)
 		if n == 0 {
-			return nil, loaded, err
+			if i == 0 || err != io.EOF {
+				g.Release(append(batch, shard))
+				return nil, loaded, err
+			}
+			// data ended on a shard boundary
+			g.Release([][]byte{shard})
+			goto padRemaining
 		}
 		loaded += n
 		switch err {
@@ -63,12 +86,43 @@ func (g *BatchLoader) Load(r io.Reader) (batch [][]byte, loaded int, err error)
 	return batch, loaded, nil
 
 padRemaining:
-	shard := make([]byte, g.ShardSize)
-	for j = range g.ShardSize {
-		shard[j] = PaddingByte
+	if g.Pool == nil {
+		shard = make([]byte, g.ShardSize)
+		for j = range g.ShardSize {
+			shard[j] = PaddingByte
+		}
+		for j = range batch[i:g.Quorum] {
+			batch[i+j] = shard
+		}
+		return batch, loaded, nil
 	}
-	for j = range batch[i:g.Quorum] {
-		batch[i+j] = shard
+	// pooled shards are released one by one, so they cannot be shared
+	for ; i < g.Quorum; i++ {
+		if shard, err = g.Pool.Get(); err != nil {
+			g.Release(batch)
+			return nil, loaded, err
+		}
+		for j = range shard {
+			shard[j] = PaddingByte
+		}
+		batch[i] = shard
 	}
 	return batch, loaded, nil
 }
+
+func (g *BatchLoader) allocate() ([]byte, error) {
+	if g.Pool == nil {
+		return make([]byte, g.ShardSize), nil
+	}
+	return g.Pool.Get()
+}
+
+// Release returns shard buffers to the [BatchLoader.Pool].
+func (g *BatchLoader) Release(batch [][]byte) {
+	if g.Pool == nil {
+		return
+	}
+	for _, shard := range batch {
+		g.Pool.Put(shard)
+	}
+}
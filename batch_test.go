@@ -85,3 +85,27 @@ func TestBatchPadding(t *testing.T) {
 		}
 	}
 }
+
+func TestBatchPaddingAfterFullShard(t *testing.T) {
+	loader := &BatchLoader{
+		Quorum:    3,
+		Shards:    5,
+		ShardSize: 4,
+	}
+
+	batch, loaded, err := loader.Load(bytes.NewBufferString("abcd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 4 {
+		t.Fatal("did not load the correct number of bytes:", loaded, "vs", 4)
+	}
+	if !bytes.Equal([]byte("abcd"), batch[0]) {
+		t.Fatalf("first shard %q does not match data", batch[0])
+	}
+	for _, shard := range batch[1:loader.Quorum] {
+		if !bytes.Equal([]byte("????"), shard) {
+			t.Fatalf("shard %q was not filled with padding bytes", shard)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package gopar3
+
+import (
+	"context"
+	"io"
+
+	"github.com/dkotik/gopar3/telomeres"
+)
+
+// NewCarvedIndex scans arbitrary containers, like disk images,
+// tape dumps, or archives, for shards embedded in them. Data that
+// does not decode into a plausible shard with a matching checksum
+// is skipped as noise. Shard positions are absolute offsets
+// in the containers, so they can be restored without extracting
+// or mounting anything.
+//
+// Telomere marks must be given, because the noise around shards
+// defeats [telomeres.DetectMarks]. Framing is detected from shards
+// carved out of the first [MarkDetectionSampleSize] bytes.
+func NewCarvedIndex(ctx context.Context, marks telomeres.Marks, files ...string) (Index, error) {
+	return ScanIndex(ctx, files, WithIndexMarks(marks.Mark, marks.Escape), WithCarving())
+}
+
+// isCarvedShard returns true for a shard that was decoded
+// without errors and carries a plausible tag. A checksum
+// alone matches one in four billion chunks of noise, which
+// is not rare enough for large disk images.
+func isCarvedShard(s *Shard, err error) bool {
+	if err != nil || s.Error != "" || s.Tag.ShardQuorum == 0 {
+		return false
+	}
+	payload := s.payloadSize()
+	if s.Tag.IsTrailer() {
+		return payload == TrailerSize
+	}
+	if payload < 1 || int(s.Tag.ShardOrder) >= max(s.Tag.Shards(), TrailerShardOrder) {
+		return false
+	}
+	// the first byte of the batch must be within the source
+	size := s.Tag.Size()
+	return uint64(s.Tag.ShardBatch)*uint64(s.Tag.ShardQuorum)*uint64(payload) < size
+}
+
+// probeCarvedShards counts carved shards within the first
+// [MarkDetectionSampleSize] bytes for [detectFraming].
+func probeCarvedShards(ctx context.Context, r *Reader) (valid int) {
+	var carved int64
+	for {
+		shard, err := r.NextShard(ctx, io.Discard)
+		if isCarvedShard(shard, err) {
+			valid++
+		} else if err == io.EOF || ctx.Err() != nil || shard.LastByte <= carved {
+			return valid
+		}
+		if carved = shard.LastByte; carved > MarkDetectionSampleSize {
+			return valid
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package gopar3
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dkotik/gopar3/telomeres"
+)
+
+func TestCarvingShardsFromNoise(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	expected, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, c := range map[string]struct {
+		options []InflateOption
+		marks   telomeres.Marks
+	}{
+		"escape":   {marks: telomeres.DefaultMarks},
+		"stuffing": {options: []InflateOption{WithByteStuffing()}, marks: telomeres.DefaultMarks},
+		"marks":    {options: []InflateOption{WithTelomereMarks('~', '^')}, marks: telomeres.Marks{Mark: '~', Escape: '^'}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			directory := t.TempDir()
+			archive := filepath.Join(directory, "archive.gopar3")
+			if err := Inflate(ctx, archive, "README.md", 3, 2, 64, c.options...); err != nil {
+				t.Fatal(err)
+			}
+			inflated, err := os.ReadFile(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			noise := rand.New(rand.NewSource(1))
+			before := make([]byte, 100_000)
+			after := make([]byte, 20_000)
+			noise.Read(before)
+			noise.Read(after)
+			container := filepath.Join(directory, "container.img")
+			if err = os.WriteFile(container, bytes.Join([][]byte{before, inflated, after}, nil), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			index, err := NewCarvedIndex(ctx, c.marks, container)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(index.Files) != 1 {
+				t.Fatalf("carved %d files instead of one", len(index.Files))
+			}
+			for _, file := range index.Files {
+				for _, shard := range file.Shards {
+					if shard.FirstByte < int64(len(before)) || shard.LastByte > int64(len(before)+len(inflated)) {
+						t.Fatalf("shard at %d-%d is outside of the embedded archive", shard.FirstByte, shard.LastByte)
+					}
+					if shard.Error != "" {
+						t.Fatalf("carved a damaged shard: %s", shard.Error)
+					}
+				}
+				b := &bytes.Buffer{}
+				if err = Restore(ctx, b, file); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(b.Bytes(), expected) {
+					t.Fatal("restored file does not match the original")
+				}
+			}
+		})
+	}
+}
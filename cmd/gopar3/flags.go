@@ -1,6 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dkotik/gopar3"
 	"github.com/urfave/cli/v2"
 )
 
@@ -28,7 +33,13 @@ var (
 		Name:    "parity",
 		Aliases: []string{"p"},
 		Value:   3,
-		Usage:   "`number` of parity shards",
+		Usage:   "`number` of parity shards, up to 255 together with the quorum, or 65535 with the leopard codec",
+	}
+
+	flagCodec = &cli.StringFlag{
+		Name:  "codec",
+		Value: gopar3.CodecReedSolomon.String(),
+		Usage: "erasure `code`: reedsolomon, or leopard for batches wider than 255 shards with shard size a multiple of 64",
 	}
 
 	flagSize = &cli.UintFlag{
@@ -37,4 +48,139 @@ var (
 		Value:   64, // TODO: fix
 		Usage:   "size of each shard in `bytes` without the metadata",
 	}
+
+	flagSync = &cli.UintFlag{
+		Name:  "sync",
+		Value: 0,
+		Usage: "insert a sync marker after every `bytes` of shard data, 0 disables",
+	}
+
+	flagStuffing = &cli.BoolFlag{
+		Name:  "stuffing",
+		Usage: "frame shards using byte stuffing with bounded overhead instead of escaping",
+	}
+
+	flagInnerCode = &cli.BoolFlag{
+		Name:  "inner-code",
+		Usage: "correct up to 16 damaged bytes in every 255 bytes of each shard with RS(255,223) codewords, at 14% overhead",
+	}
+
+	flagMark = &cli.StringFlag{
+		Name:  "mark",
+		Value: ":",
+		Usage: "telomere `byte` that frames shards; scanning reads it from the archive header or detects it, unless given",
+	}
+
+	flagEscape = &cli.StringFlag{
+		Name:  "escape",
+		Value: "\\",
+		Usage: "`byte` that escapes telomere marks inside shards; scanning reads it from the archive header or detects it, unless given",
+	}
+
+	flagJobs = &cli.UintFlag{
+		Name:    "jobs",
+		Aliases: []string{"j"},
+		Usage:   "`number` of shard batches processed concurrently, defaults to the number of processors",
+	}
+
+	flagSinglePass = &cli.BoolFlag{
+		Name:  "single-pass",
+		Usage: "read each input once and record its checksum in a trailer after the last shard",
+	}
+
+	flagOverwrite = &cli.BoolFlag{
+		Name:  "overwrite",
+		Usage: "replace existing output files",
+	}
+
+	flagFormat = &cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"f"},
+		Value:   "json",
+		Usage:   "output `format`: json, summary, health, sources, ndjson with one shard per line, or csv",
+	}
+
+	flagOnlyErrors = &cli.BoolFlag{
+		Name:  "only-errors",
+		Usage: "list only damaged shards and files that have them or cannot be restored",
+	}
+
+	flagFile = &cli.StringSliceFlag{
+		Name:  "file",
+		Usage: "list only the file with given `differentiator`, can be repeated",
+	}
+
+	flagCarve = &cli.BoolFlag{
+		Name:  "carve",
+		Usage: "scan arbitrary containers, like disk images, for shards framed by --mark and --escape, skipping the rest",
+	}
+
+	flagMapfile = &cli.StringSliceFlag{
+		Name:  "mapfile",
+		Usage: "treat shards in areas of a disk image that a ddrescue mapfile does not report as finished as erasures, given as `SOURCE=MAPFILE`",
+	}
+
+	flagUnreadable = &cli.StringSliceFlag{
+		Name:  "unreadable",
+		Usage: "treat shards overlapping a range of bad bytes as erasures, given as `SOURCE=OFFSET+SIZE`",
+	}
+
+	flagExcludeBelow = &cli.Float64Flag{
+		Name:  "exclude-below",
+		Usage: "ignore all shards of sources with a smaller share of healthy shards, from 0 to 1",
+	}
+
+	flagDemoteBelow = &cli.Float64Flag{
+		Name:  "demote-below",
+		Usage: "use shards of sources with a smaller share of healthy shards, from 0 to 1, only when other sources lack them",
+	}
+
+	flagMemoryLimit = &cli.StringFlag{
+		Name:  "memory-limit",
+		Usage: "`size` of memory for batch buffers, like 512M or 2G; buffers past the limit spill into a temporary file",
+	}
 )
+
+// parseByteSize reads a number of bytes with an optional
+// binary unit suffix: K, M, G, or T. Empty string is zero.
+func parseByteSize(s string) (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	original, multiplier := s, uint64(1)
+	switch s[len(s)-1] {
+	case 'K', 'k':
+		multiplier = 1 << 10
+	case 'M', 'm':
+		multiplier = 1 << 20
+	case 'G', 'g':
+		multiplier = 1 << 30
+	case 'T', 't':
+		multiplier = 1 << 40
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", original, err)
+	}
+	return n * multiplier, nil
+}
+
+// parseByteRange reads a source with a range of bytes given
+// as SOURCE=OFFSET+SIZE. Numbers can be hexadecimal with 0x prefix.
+func parseByteRange(s string) (source string, r gopar3.ByteRange, err error) {
+	source, span, ok := strings.Cut(s, "=")
+	offset, size, ok2 := strings.Cut(span, "+")
+	if !ok || !ok2 {
+		return "", r, fmt.Errorf("range %q must be given as SOURCE=OFFSET+SIZE", s)
+	}
+	if r.Offset, err = strconv.ParseInt(offset, 0, 64); err != nil {
+		return "", r, fmt.Errorf("invalid range offset %q: %w", offset, err)
+	}
+	if r.Size, err = strconv.ParseInt(size, 0, 64); err != nil {
+		return "", r, fmt.Errorf("invalid range size %q: %w", size, err)
+	}
+	return source, r, nil
+}
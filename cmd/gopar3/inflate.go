@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/dkotik/gopar3"
 	"github.com/urfave/cli/v2"
 )
@@ -10,17 +13,60 @@ func commandInflate(ctx *cli.Context) (err error) {
 	if len(sources) == 0 {
 		return cli.ShowSubcommandHelp(ctx)
 	}
+	quorum, parity := ctx.Uint("quorum"), ctx.Uint("parity")
+	if quorum+parity > gopar3.WideShardLimit {
+		return fmt.Errorf("quorum and parity add up to %d shards, more than %d", quorum+parity, gopar3.WideShardLimit)
+	}
+	codec, err := gopar3.ParseCodec(ctx.String("codec"))
+	if err != nil {
+		return err
+	}
+	options := []gopar3.InflateOption{
+		gopar3.WithCodec(codec),
+		gopar3.WithInflateProgress(reportProgress()),
+	}
+	if sync := ctx.Int("sync"); sync > 0 {
+		options = append(options, gopar3.WithSyncMarkers(sync))
+	}
+	if ctx.Bool("stuffing") {
+		options = append(options, gopar3.WithByteStuffing())
+	}
+	if ctx.Bool("inner-code") {
+		options = append(options, gopar3.WithInnerCode())
+	}
+	mark, escape := ctx.String("mark"), ctx.String("escape")
+	if len(mark) != 1 || len(escape) != 1 {
+		return errors.New("telomere mark and escape must be single bytes")
+	}
+	options = append(options, gopar3.WithTelomereMarks(mark[0], escape[0]))
+	if ctx.Bool("single-pass") {
+		options = append(options, gopar3.WithSinglePass())
+	}
+	if ctx.Bool("overwrite") {
+		options = append(options, gopar3.WithOverwrite())
+	}
+	if jobs := ctx.Uint("jobs"); jobs > 0 {
+		options = append(options, gopar3.WithInflateJobs(int(jobs)))
+	}
+	memoryLimit, err := parseByteSize(ctx.String("memory-limit"))
+	if err != nil {
+		return err
+	}
+	if memoryLimit > 0 {
+		options = append(options, gopar3.WithInflateMemoryLimit(memoryLimit))
+	}
 	for _, source := range sources {
 		// fmt.Println("inflating: ", source)
 		if err = gopar3.Inflate(
 			ctx.Context,
 			ctx.String("output"),
 			source,
-			uint8(ctx.Uint("quorum")),
-			uint8(ctx.Uint("parity")),
+			uint16(quorum),
+			uint16(parity),
 			ctx.Int("size"),
+			options...,
 		); err != nil {
-			return err
+			return resumable(err)
 		}
 	}
 	return nil
@@ -1,24 +1,380 @@
 package main
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/dkotik/gopar3"
 	"github.com/urfave/cli/v2"
 )
 
+// inspectFormat renders the [inspection]. Formats that list
+// shards show only the damaged ones, when errors are filtered.
+type inspectFormat struct {
+	write  func(io.Writer, inspection) error
+	shards bool
+}
+
+// inspection is a [gopar3.Index] with the health of its sources,
+// which is assessed before the files or shards are filtered.
+type inspection struct {
+	gopar3.Index
+	Health []gopar3.SourceHealth
+}
+
+var inspectFormats = map[string]inspectFormat{
+	"json":    {write: writeIndexJSON, shards: true},
+	"summary": {write: writeIndexSummary},
+	"health":  {write: writeIndexHealth},
+	"sources": {write: writeSourceHealth},
+	"ndjson":  {write: writeShardsNDJSON, shards: true},
+	"csv":     {write: writeShardsCSV, shards: true},
+}
+
 func commandInspect(ctx *cli.Context) (err error) {
 	sources := ctx.Args().Slice()
 	if len(sources) == 0 {
 		return cli.ShowSubcommandHelp(ctx)
 	}
-	index, err := gopar3.NewIndex(ctx.Context, sources...)
+	format, ok := inspectFormats[ctx.String("format")]
+	if !ok {
+		return fmt.Errorf("unknown format %q", ctx.String("format"))
+	}
+	index, err := indexSources(ctx, sources)
 	if err != nil {
 		return err
 	}
-	encoder := json.NewEncoder(os.Stdout)
+	return format.inspect(os.Stdout, index, ctx.StringSlice("file"), ctx.Bool("only-errors"))
+}
+
+// inspect filters the index and writes it. The health of
+// the sources is assessed before filtering.
+func (f inspectFormat) inspect(w io.Writer, index gopar3.Index, files []string, onlyErrors bool) error {
+	health := index.Health()
+	index = filterIndex(index, files, onlyErrors)
+	if onlyErrors && f.shards {
+		index = onlyDamaged(index)
+	}
+	return f.write(w, inspection{Index: index, Health: health})
+}
+
+// indexSources scans the sources for shards. With the carve flag,
+// shards are carved out of arbitrary containers framed by the
+// telomere marks given in flags. Unreadable ranges given in flags
+// are skipped, so the shards overlapping them become erasures.
+func indexSources(ctx *cli.Context, sources []string) (index gopar3.Index, err error) {
+	options := []gopar3.IndexOption{gopar3.WithIndexProgress(reportProgress())}
+	carve := ctx.Bool("carve")
+	if carve || ctx.IsSet("mark") || ctx.IsSet("escape") {
+		mark, escape := ctx.String("mark"), ctx.String("escape")
+		if len(mark) != 1 || len(escape) != 1 {
+			return gopar3.Index{}, errors.New("telomere mark and escape must be single bytes")
+		}
+		options = append(options, gopar3.WithIndexMarks(mark[0], escape[0]))
+	}
+	if carve {
+		options = append(options, gopar3.WithCarving())
+	}
+	for _, value := range ctx.StringSlice("mapfile") {
+		source, mapfile, ok := strings.Cut(value, "=")
+		if !ok {
+			return gopar3.Index{}, fmt.Errorf("mapfile %q must be given as SOURCE=MAPFILE", value)
+		}
+		f, err := os.Open(mapfile)
+		if err != nil {
+			return gopar3.Index{}, err
+		}
+		ranges, err := gopar3.ReadMapfile(f)
+		if err = errors.Join(err, f.Close()); err != nil {
+			return gopar3.Index{}, err
+		}
+		options = append(options, gopar3.WithUnreadable(source, ranges...))
+	}
+	for _, value := range ctx.StringSlice("unreadable") {
+		source, bad, err := parseByteRange(value)
+		if err != nil {
+			return gopar3.Index{}, err
+		}
+		options = append(options, gopar3.WithUnreadable(source, bad))
+	}
+	return gopar3.ScanIndex(ctx.Context, sources, options...)
+}
+
+// filterIndex keeps the files with given differentiators, if any.
+// Only errors keeps the files that have damaged shards
+// or cannot be restored.
+func filterIndex(index gopar3.Index, files []string, onlyErrors bool) gopar3.Index {
+	filtered := gopar3.Index{
+		Files:   make(map[string]*gopar3.File, len(index.Files)),
+		Skipped: index.Skipped,
+		Headers: index.Headers,
+	}
+	for differentiator, f := range index.Files {
+		if len(files) > 0 && !slices.Contains(files, differentiator) {
+			continue
+		}
+		if onlyErrors && f.Error == "" && !slices.ContainsFunc(f.Shards, isDamaged) {
+			continue
+		}
+		filtered.Files[differentiator] = f
+	}
+	return filtered
+}
+
+func isDamaged(shard *gopar3.Shard) bool {
+	return shard.Error != ""
+}
+
+// onlyDamaged removes healthy shards from a copy of the index.
+func onlyDamaged(index gopar3.Index) gopar3.Index {
+	damaged := gopar3.Index{
+		Files:   make(map[string]*gopar3.File, len(index.Files)),
+		Skipped: index.Skipped,
+		Headers: index.Headers,
+	}
+	for differentiator, f := range index.Files {
+		copied := *f
+		copied.Shards = nil
+		for _, shard := range f.Shards {
+			if isDamaged(shard) {
+				copied.Shards = append(copied.Shards, shard)
+			}
+		}
+		damaged.Files[differentiator] = &copied
+	}
+	return damaged
+}
+
+// sortedFiles returns the differentiators of the index in order,
+// so that the output does not change between runs.
+func sortedFiles(index gopar3.Index) []string {
+	differentiators := make([]string, 0, len(index.Files))
+	for differentiator := range index.Files {
+		differentiators = append(differentiators, differentiator)
+	}
+	slices.Sort(differentiators)
+	return differentiators
+}
+
+func writeIndexJSON(w io.Writer, index inspection) error {
+	encoder := json.NewEncoder(w)
 	encoder.SetEscapeHTML(false)
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(index)
 }
+
+func writeSourceHealth(w io.Writer, index inspection) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, health := range index.Health {
+		if err := encoder.Encode(health); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileHealth is a [gopar3.File] without its shards.
+type fileHealth struct {
+	File          string
+	Size          uint64
+	Quorum        uint16
+	Parity        uint16
+	Codec         gopar3.Codec
+	ShardSize     int64
+	Batches       uint16
+	Shards        int
+	Damaged       int
+	Divergent     int                    `json:",omitempty"`
+	Mismatches    []gopar3.MismatchError `json:",omitempty"`
+	CastagnoliSum uint32
+	Unverified    bool `json:",omitempty"`
+	Error         string
+}
+
+func newFileHealth(differentiator string, f *gopar3.File) fileHealth {
+	health := fileHealth{
+		File:          differentiator,
+		Size:          f.Size,
+		Quorum:        f.Quorum,
+		Parity:        f.Parity,
+		Codec:         f.Codec,
+		ShardSize:     f.ShardSize,
+		Batches:       f.Batches,
+		Shards:        len(f.Shards),
+		Divergent:     f.Divergent,
+		Mismatches:    f.Mismatches,
+		CastagnoliSum: f.CastagnoliSum,
+		Unverified:    f.Unverified,
+		Error:         f.Error,
+	}
+	for _, shard := range f.Shards {
+		if shard.IsDamaged() {
+			health.Damaged++
+		}
+	}
+	return health
+}
+
+func writeIndexHealth(w io.Writer, index inspection) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, differentiator := range sortedFiles(index.Index) {
+		if err := encoder.Encode(newFileHealth(differentiator, index.Files[differentiator])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeIndexSummary(w io.Writer, index inspection) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if len(index.Headers) > 0 {
+		if _, err := fmt.Fprintln(table, "ARCHIVE\tOFFSET\tFORMAT\tCODEC\tQUORUM\tPARITY\tSHARD SIZE\tCREATED"); err != nil {
+			return err
+		}
+		for _, header := range index.Headers {
+			codec := header.Codec.String()
+			if header.InnerCode {
+				codec += " with inner code"
+			}
+			if _, err := fmt.Fprintf(table, "%s\t%d\t%d\t%s\t%d\t%d\t%d\t%s\n",
+				header.Source,
+				header.FirstByte,
+				header.Version,
+				codec,
+				header.Quorum,
+				header.Parity,
+				header.ShardSize,
+				header.Created.Format(time.RFC3339),
+			); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(table); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(table, "FILE\tSIZE\tBATCHES\tSHARDS\tDAMAGED\tSTATUS"); err != nil {
+		return err
+	}
+	for _, differentiator := range sortedFiles(index.Index) {
+		health := newFileHealth(differentiator, index.Files[differentiator])
+		status := "restorable"
+		switch {
+		case health.Error != "":
+			status = health.Error
+		case health.Unverified:
+			status = "restorable, unverified"
+		}
+		if health.Divergent > 0 {
+			status += fmt.Sprintf(", %d divergent shards", health.Divergent)
+		}
+		for _, mismatch := range health.Mismatches {
+			if mismatch.Shards > 0 {
+				status += ", " + mismatch.Error()
+			}
+		}
+		if _, err := fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t%s\n",
+			health.File,
+			health.Size,
+			health.Batches,
+			health.Shards,
+			health.Damaged,
+			status,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(table, "\nSOURCE\tSHARDS\tDAMAGED\tCORRECTED\tSKIPPED\tHEALTH\tDAMAGE"); err != nil {
+		return err
+	}
+	for _, health := range index.Health {
+		damage := "-"
+		if len(health.DamagedRanges) > 0 {
+			damage = fmt.Sprintf("%d-%d in %d ranges", health.FirstDamage, health.LastDamage+1, len(health.DamagedRanges))
+		}
+		if _, err := fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t%.1f%%\t%s\n",
+			health.Source,
+			health.Shards,
+			health.Damaged,
+			health.Corrected,
+			health.Skipped,
+			health.Score()*100,
+			damage,
+		); err != nil {
+			return err
+		}
+	}
+	for _, skipped := range index.Skipped {
+		if _, err := fmt.Fprintf(table, "%s\tskipped %s: %s\n",
+			skipped.Source,
+			skipped.ByteRange,
+			skipped.Error,
+		); err != nil {
+			return err
+		}
+	}
+	return table.Flush()
+}
+
+// writeShardsNDJSON writes one JSON line per shard. Like the other
+// formats, the lines are written after the scan, not during it,
+// because the files are sorted and filtered as a whole.
+func writeShardsNDJSON(w io.Writer, index inspection) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, differentiator := range sortedFiles(index.Index) {
+		for _, shard := range index.Files[differentiator].Shards {
+			if err := encoder.Encode(struct {
+				File string
+				*gopar3.Shard
+			}{
+				File:  differentiator,
+				Shard: shard,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeShardsCSV(w io.Writer, index inspection) error {
+	table := csv.NewWriter(w)
+	if err := table.Write([]string{
+		"file", "source", "first_byte", "last_byte", "size",
+		"batch", "order", "castagnoli_sum", "corrected", "error",
+	}); err != nil {
+		return err
+	}
+	for _, differentiator := range sortedFiles(index.Index) {
+		for _, shard := range index.Files[differentiator].Shards {
+			if err := table.Write([]string{
+				differentiator,
+				shard.Source,
+				strconv.FormatInt(shard.FirstByte, 10),
+				strconv.FormatInt(shard.LastByte, 10),
+				strconv.FormatInt(shard.Size, 10),
+				strconv.Itoa(int(shard.Tag.ShardBatch)),
+				strconv.Itoa(int(shard.Tag.ShardOrder)),
+				strconv.FormatUint(uint64(shard.CastagnoliSum), 10),
+				strconv.Itoa(shard.Corrected),
+				shard.Error,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	table.Flush()
+	return table.Error()
+}
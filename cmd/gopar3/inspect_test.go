@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dkotik/gopar3"
+)
+
+// testInspectIndex returns an index of a healthy file on
+// the first disk, a file with one damaged shard on the second,
+// and a file that lost all of its shards.
+func testInspectIndex() gopar3.Index {
+	shard := func(source string, order uint16, err string) *gopar3.Shard {
+		return &gopar3.Shard{
+			Source:    source,
+			FirstByte: int64(order) * 20,
+			LastByte:  int64(order)*20 + 20,
+			Size:      20,
+			Tag:       gopar3.Tag{ShardOrder: order},
+			Error:     err,
+		}
+	}
+	return gopar3.Index{
+		Sources: []string{"disk1", "disk2"},
+		Files: map[string]*gopar3.File{
+			"healthy": {
+				Quorum: 2, ShardSize: 8, Size: 16, Batches: 1,
+				Shards: []*gopar3.Shard{shard("disk1", 0, ""), shard("disk1", 1, "")},
+			},
+			"damaged": {
+				Quorum: 1, Parity: 1, ShardSize: 8, Size: 8, Batches: 1,
+				Shards: []*gopar3.Shard{shard("disk2", 0, ""), shard("disk2", 1, "corrupted shard")},
+			},
+			"lost": {
+				Error: "there are no recoverable shards",
+			},
+		},
+	}
+}
+
+func TestFilterIndex(t *testing.T) {
+	cases := []struct {
+		name       string
+		files      []string
+		onlyErrors bool
+		expected   []string
+	}{
+		{name: "everything", expected: []string{"damaged", "healthy", "lost"}},
+		{name: "only errors", onlyErrors: true, expected: []string{"damaged", "lost"}},
+		{name: "one file", files: []string{"healthy"}, expected: []string{"healthy"}},
+		{name: "errors of files", files: []string{"healthy", "damaged"}, onlyErrors: true, expected: []string{"damaged"}},
+		{name: "unknown file", files: []string{"missing"}, expected: []string{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			filtered := filterIndex(testInspectIndex(), c.files, c.onlyErrors)
+			if files := sortedFiles(filtered); !slices.Equal(files, c.expected) {
+				t.Fatalf("kept files %v instead of %v", files, c.expected)
+			}
+		})
+	}
+}
+
+func TestInspectFormats(t *testing.T) {
+	cases := []struct {
+		format     string
+		files      []string
+		onlyErrors bool
+		// records are files for json, rows for csv without
+		// the header, and lines for the other JSON formats
+		records  int
+		contains []string
+		excludes []string
+	}{
+		{format: "json", records: 3, contains: []string{`"corrupted shard"`}},
+		{format: "json", onlyErrors: true, records: 2, excludes: []string{`"healthy"`}},
+		{format: "summary", contains: []string{"healthy", "damaged", "there are no recoverable shards", "disk2"}},
+		{format: "summary", files: []string{"healthy"}, contains: []string{"healthy", "disk2"}, excludes: []string{"lost"}},
+		{format: "health", records: 3},
+		{format: "health", onlyErrors: true, records: 2, excludes: []string{`"healthy"`}},
+		{format: "sources", records: 2, contains: []string{`"disk1"`, `"disk2"`}},
+		{format: "sources", files: []string{"healthy"}, records: 2},
+		{format: "ndjson", records: 4},
+		{format: "ndjson", onlyErrors: true, records: 1, contains: []string{`"corrupted shard"`}},
+		{format: "ndjson", files: []string{"healthy"}, records: 2, excludes: []string{"disk2"}},
+		{format: "csv", records: 4},
+		{format: "csv", onlyErrors: true, records: 1, contains: []string{"damaged,disk2,20,40"}},
+		{format: "csv", files: []string{"damaged"}, records: 2, excludes: []string{"disk1"}},
+	}
+	tested := make(map[string]bool)
+	for _, c := range cases {
+		tested[c.format] = true
+		t.Run(c.format, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			if err := inspectFormats[c.format].inspect(b, testInspectIndex(), c.files, c.onlyErrors); err != nil {
+				t.Fatal(err)
+			}
+			output := b.String()
+			if records := countRecords(t, c.format, output); records != c.records {
+				t.Errorf("wrote %d records instead of %d:\n%s", records, c.records, output)
+			}
+			for _, expected := range c.contains {
+				if !strings.Contains(output, expected) {
+					t.Errorf("output does not contain %q:\n%s", expected, output)
+				}
+			}
+			for _, unexpected := range c.excludes {
+				if strings.Contains(output, unexpected) {
+					t.Errorf("output contains %q:\n%s", unexpected, output)
+				}
+			}
+		})
+	}
+	for format := range inspectFormats {
+		if !tested[format] {
+			t.Errorf("format %q is not tested", format)
+		}
+	}
+}
+
+// countRecords parses the output of the format. The summary
+// table has no records to count.
+func countRecords(t *testing.T, format, output string) int {
+	t.Helper()
+	switch format {
+	case "summary":
+		return 0
+	case "json":
+		var index struct {
+			Files map[string]json.RawMessage
+		}
+		if err := json.Unmarshal([]byte(output), &index); err != nil {
+			t.Fatal(err)
+		}
+		return len(index.Files)
+	case "csv":
+		rows, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(rows) - 1
+	default:
+		lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+		if output == "" {
+			return 0
+		}
+		for _, line := range lines {
+			if !json.Valid([]byte(line)) {
+				t.Fatalf("invalid JSON line: %s", line)
+			}
+		}
+		return len(lines)
+	}
+}
@@ -2,6 +2,7 @@
 Package main provides a command line interface to:
 
 - [gopar3.Inflate]
+- [gopar3.Update]
 - [gopar3.Split]
 - [gopar3.Scatter]
 */
@@ -9,8 +10,12 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/urfave/cli/v2"
 )
@@ -29,20 +34,45 @@ func main() {
 					flagOutput,
 					flagQuorum,
 					flagParity,
+					flagCodec,
 					flagSize,
+					flagSync,
+					flagStuffing,
+					flagInnerCode,
+					flagMark,
+					flagEscape,
+					flagJobs,
+					flagSinglePass,
+					flagOverwrite,
+					flagMemoryLimit,
 				},
 				Action: commandInflate,
 			},
+			{
+				Name:      "update",
+				Aliases:   []string{"u"},
+				Usage:     "copy unchanged batches of an archive and inflate the rest from a new version of its source",
+				ArgsUsage: "ARCHIVE SOURCE",
+				Flags: []cli.Flag{
+					flagJobs,
+					flagMemoryLimit,
+				},
+				Action: commandUpdate,
+			},
 			{
 				Name:      "inspect",
 				Aliases:   []string{"s"},
 				Usage:     "scan each input file or directory for data shards",
 				ArgsUsage: "[...FILES]",
-				Flags:     []cli.Flag{
-					// flagOutput,
-					// flagQuorum,
-					// flagParity,
-					// flagSize,
+				Flags: []cli.Flag{
+					flagFormat,
+					flagOnlyErrors,
+					flagFile,
+					flagCarve,
+					flagMark,
+					flagEscape,
+					flagMapfile,
+					flagUnreadable,
 				},
 				Action: commandInspect,
 			},
@@ -51,8 +81,19 @@ func main() {
 				Aliases:   []string{"r"},
 				Usage:     "recover original files from shards kept in source files",
 				ArgsUsage: "[...FILES]",
-				Flags:     []cli.Flag{},
-				Action:    commandRestore,
+				Flags: []cli.Flag{
+					flagJobs,
+					flagMemoryLimit,
+					flagCarve,
+					flagMark,
+					flagEscape,
+					flagMapfile,
+					flagUnreadable,
+					flagExcludeBelow,
+					flagDemoteBelow,
+					flagOverwrite,
+				},
+				Action: commandRestore,
 			},
 			{
 				Name:      "checksum",
@@ -65,11 +106,23 @@ func main() {
 		},
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	// TODO: add cancellation
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop() // second signal terminates immediately
+	}()
 
 	if err := app.RunContext(ctx, os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// resumable explains that an interrupted inflate or update kept
+// its partial outputs, which the next run resumes.
+func resumable(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("interrupted, partial output files were kept for resuming: %w", err)
+	}
+	return err
+}
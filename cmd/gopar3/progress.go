@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dkotik/gopar3"
+)
+
+// jsonProgressInterval is the time between JSON progress lines
+// written when standard error is not a terminal.
+const jsonProgressInterval = 5 * time.Second
+
+const progressBarWidth = 30
+
+// reportProgress reports the progress of library operations
+// to standard error: as a progress bar on terminals or as periodic
+// JSON lines otherwise.
+func reportProgress() gopar3.ProgressFunc {
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return progressBar(os.Stderr)
+	}
+	return progressJSON(os.Stderr)
+}
+
+func progressBar(w io.Writer) gopar3.ProgressFunc {
+	return func(p gopar3.Progress) {
+		fraction := p.Fraction()
+		filled := int(fraction * progressBarWidth)
+		eta := "--"
+		if p.Done {
+			eta = p.Elapsed.Round(time.Second).String()
+		} else if remaining := p.ETA(); remaining > 0 {
+			eta = remaining.Round(time.Second).String()
+		}
+		errors := ""
+		if p.Errors > 0 {
+			errors = fmt.Sprintf(" %d damaged", p.Errors)
+		}
+		_, _ = fmt.Fprintf(w, "\r%-8s [%s%s] %5.1f%% %s/%s%s %s\x1b[K",
+			p.Operation,
+			strings.Repeat("=", filled),
+			strings.Repeat(" ", progressBarWidth-filled),
+			fraction*100,
+			formatBytes(p.BytesRead),
+			formatBytes(p.BytesTotal),
+			errors,
+			eta,
+		)
+		if p.Done {
+			_, _ = fmt.Fprintln(w)
+		}
+	}
+}
+
+func progressJSON(w io.Writer) gopar3.ProgressFunc {
+	encoder := json.NewEncoder(w)
+	var last time.Time
+	return func(p gopar3.Progress) {
+		if now := time.Now(); p.Done || now.Sub(last) >= jsonProgressInterval {
+			last = now
+			_ = encoder.Encode(struct {
+				gopar3.Progress
+				Fraction float64
+				ETA      time.Duration
+			}{
+				Progress: p,
+				Fraction: p.Fraction(),
+				ETA:      p.ETA(),
+			})
+		}
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
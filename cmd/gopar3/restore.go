@@ -2,7 +2,7 @@ package main
 
 import (
 	"errors"
-	"os"
+	"fmt"
 
 	"github.com/dkotik/gopar3"
 	"github.com/urfave/cli/v2"
@@ -14,23 +14,80 @@ func commandRestore(cliCtx *cli.Context) (err error) {
 		return cli.ShowSubcommandHelp(cliCtx)
 	}
 
-	index, err := gopar3.NewIndex(cliCtx.Context, sources...)
+	index, err := indexSources(cliCtx, sources)
 	if err != nil {
 		return err
 	}
-	if len(index) == 0 {
+	if len(index.Files) == 0 {
 		return errors.New("no files to restore")
 	}
+	holdBackSources(cliCtx, &index)
 
-	var w *os.File
-	for differentiator, file := range index {
-		w, err = os.Create(differentiator + ".tmp") // TODO: check if exists
+	options := []gopar3.RestoreOption{gopar3.WithRestoreProgress(reportProgress())}
+	if jobs := cliCtx.Uint("jobs"); jobs > 0 {
+		options = append(options, gopar3.WithRestoreJobs(int(jobs)))
+	}
+
+	memoryLimit, err := parseByteSize(cliCtx.String("memory-limit"))
+	if err != nil {
+		return err
+	}
+	if memoryLimit > 0 {
+		options = append(options, gopar3.WithRestoreMemoryLimit(memoryLimit))
+	}
+
+	var w *gopar3.AtomicFile
+	for differentiator, file := range index.Files {
+		if file.Divergent > 0 {
+			fmt.Fprintf(cliCtx.App.ErrWriter,
+				"warning: %d shards of %s have checksum-clean copies that differ, so different archives share the differentiator; all such copies are ignored\n",
+				file.Divergent, differentiator)
+		}
+		for _, mismatch := range file.Mismatches {
+			if mismatch.Shards > 0 {
+				fmt.Fprintf(cliCtx.App.ErrWriter,
+					"warning: %s of %s; such shards are ignored\n",
+					mismatch.Error(), differentiator)
+			}
+		}
+		if file.Unverified {
+			fmt.Fprintf(cliCtx.App.ErrWriter,
+				"warning: the trailer of single-pass archive %s was lost, so the restored data cannot be verified against the source checksum\n",
+				differentiator)
+		}
+		w, err = gopar3.CreateAtomicFile(differentiator+".tmp", cliCtx.Bool("overwrite"))
 		if err != nil {
 			return err
 		}
-		err = errors.Join(gopar3.Restore(cliCtx.Context, w, file), w.Close())
+		err = w.Finish(gopar3.Restore(cliCtx.Context, w, file, options...))
 		break
 	}
 
 	return err
 }
+
+// holdBackSources excludes or demotes the sources whose share
+// of healthy shards is below the thresholds given in flags.
+func holdBackSources(cliCtx *cli.Context, index *gopar3.Index) {
+	exclude, demote := cliCtx.Float64("exclude-below"), cliCtx.Float64("demote-below")
+	var excluded, demoted []string
+	for _, health := range index.Health() {
+		action := ""
+		switch score := health.Score(); {
+		case score < exclude:
+			excluded, action = append(excluded, health.Source), "excluded"
+		case score < demote:
+			demoted, action = append(demoted, health.Source), "demoted"
+		default:
+			continue
+		}
+		fmt.Fprintf(cliCtx.App.ErrWriter, "warning: %s was %s, because %d of its %d shards are damaged\n",
+			health.Source, action, health.Damaged, health.Shards)
+	}
+	if len(excluded) > 0 {
+		index.ExcludeSources(excluded...)
+	}
+	if len(demoted) > 0 {
+		index.DemoteSources(demoted...)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/dkotik/gopar3"
+	"github.com/urfave/cli/v2"
+)
+
+func commandUpdate(ctx *cli.Context) (err error) {
+	if ctx.Args().Len() != 2 {
+		return cli.ShowSubcommandHelp(ctx)
+	}
+	options := []gopar3.InflateOption{gopar3.WithInflateProgress(reportProgress())}
+	if jobs := ctx.Uint("jobs"); jobs > 0 {
+		options = append(options, gopar3.WithInflateJobs(int(jobs)))
+	}
+	memoryLimit, err := parseByteSize(ctx.String("memory-limit"))
+	if err != nil {
+		return err
+	}
+	if memoryLimit > 0 {
+		options = append(options, gopar3.WithInflateMemoryLimit(memoryLimit))
+	}
+	return resumable(gopar3.Update(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1), options...))
+}
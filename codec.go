@@ -0,0 +1,101 @@
+package gopar3
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// Codec is the erasure code that computes the parity shards
+// of each batch.
+type Codec uint8
+
+const (
+	// CodecReedSolomon is Reed-Solomon over GF(2^8). Batches are
+	// limited to [ShardLimit] shards and tags keep the standard size.
+	CodecReedSolomon Codec = iota
+
+	// CodecLeopard is Leopard Reed-Solomon over GF(2^16), which
+	// encodes wide batches of up to [WideShardLimit] shards in
+	// O(n log n) time. Shard size must be a multiple of
+	// [LeopardShardSizeMultiple]. Tags carry an extension
+	// to number the shards, see [SourceSizeExtended].
+	CodecLeopard
+)
+
+const (
+	// WideShardLimit is the most shards that a batch encoded
+	// by [CodecLeopard] can have.
+	WideShardLimit = math.MaxUint16
+
+	// LeopardShardSizeMultiple aligns the shards of [CodecLeopard].
+	LeopardShardSizeMultiple = 64
+)
+
+// ParseCodec returns the codec with the given name.
+func ParseCodec(name string) (Codec, error) {
+	for _, codec := range [...]Codec{CodecReedSolomon, CodecLeopard} {
+		if codec.String() == name {
+			return codec, nil
+		}
+	}
+	return CodecReedSolomon, fmt.Errorf("unknown codec %q", name)
+}
+
+func (c Codec) String() string {
+	switch c {
+	case CodecReedSolomon:
+		return "reedsolomon"
+	case CodecLeopard:
+		return "leopard"
+	default:
+		return fmt.Sprintf("codec%d", uint8(c))
+	}
+}
+
+// MarshalText encodes the codec by name for indexes saved as JSON.
+func (c Codec) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes the codec from its name.
+func (c *Codec) UnmarshalText(b []byte) (err error) {
+	*c, err = ParseCodec(string(b))
+	return err
+}
+
+// validate checks that batches with the given parameters can be
+// encoded and numbered in tags.
+func (c Codec) validate(quorum, parity, shardSize int) error {
+	shards := quorum + parity
+	switch c {
+	case CodecReedSolomon:
+		if shards > ShardLimit {
+			return fmt.Errorf("shard quorum and parity add up to %d shards, more than %d that %s can encode", shards, ShardLimit, c)
+		}
+	case CodecLeopard:
+		if shards > WideShardLimit {
+			return fmt.Errorf("shard quorum and parity add up to %d shards, more than %d that %s can encode", shards, WideShardLimit, c)
+		}
+		if shardSize%LeopardShardSizeMultiple != 0 {
+			return fmt.Errorf("shard size %d is not a multiple of %d required by %s", shardSize, LeopardShardSizeMultiple, c)
+		}
+	default:
+		return fmt.Errorf("unknown %s", c)
+	}
+	return nil
+}
+
+// newEncoder prepares the erasure code for batches
+// of quorum data shards and parity shards.
+func (c Codec) newEncoder(quorum, parity int) (reedsolomon.Encoder, error) {
+	switch c {
+	case CodecReedSolomon:
+		return reedsolomon.New(quorum, parity)
+	case CodecLeopard:
+		return reedsolomon.New(quorum, parity, reedsolomon.WithLeopardGF16(true))
+	default:
+		return nil, fmt.Errorf("unknown %s", c)
+	}
+}
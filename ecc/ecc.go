@@ -0,0 +1,210 @@
+/*
+Package ecc corrects byte errors in place using Reed-Solomon
+RS(255,223) codewords over GF(2^8).
+
+Data is split into codewords of [DataSize] bytes followed by
+[ParitySize] bytes of parity. Each codeword corrects up to
+[CorrectableErrors] damaged bytes anywhere in it, without knowing
+where they are. The final codeword is shortened to the remaining
+data, so the overhead never exceeds [ParitySize] bytes
+for every [DataSize] bytes.
+
+Unlike erasure codes, which rebuild lost pieces that are known
+to be missing, the codewords repair random bit rot inside data
+that is otherwise intact.
+*/
+package ecc
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	// DataSize is the number of data bytes in a full codeword.
+	DataSize = 223
+
+	// ParitySize is the number of parity bytes that follow
+	// the data of every codeword.
+	ParitySize = 32
+
+	// CodewordSize is the length of a full codeword.
+	CodewordSize = DataSize + ParitySize
+
+	// CorrectableErrors is the most damaged bytes
+	// that a codeword can correct.
+	CorrectableErrors = ParitySize / 2
+)
+
+var (
+	// ErrShortCodeword is returned for codewords
+	// that do not have any data after the parity.
+	ErrShortCodeword = errors.New("codeword is not longer than its parity")
+
+	// ErrUncorrectable is returned for codewords with more
+	// damaged bytes than [CorrectableErrors].
+	ErrUncorrectable = errors.New("codeword has too many errors to correct")
+)
+
+// EncodedSize returns the length of n data bytes after encoding.
+func EncodedSize(n int) int {
+	return n + (n+DataSize-1)/DataSize*ParitySize
+}
+
+// Encode appends the codewords of data to dst.
+func Encode(dst, data []byte) []byte {
+	for len(data) > 0 {
+		n := min(len(data), DataSize)
+		dst = append(dst, data[:n]...)
+		dst = append(dst, make([]byte, ParitySize)...)
+		computeParity(data[:n], dst[len(dst)-ParitySize:])
+		data = data[n:]
+	}
+	return dst
+}
+
+// computeParity divides the data shifted by [ParitySize]
+// bytes by the generator polynomial and stores the remainder.
+func computeParity(data, parity []byte) {
+	clear(parity)
+	for _, b := range data {
+		feedback := b ^ parity[0]
+		copy(parity, parity[1:])
+		parity[ParitySize-1] = 0
+		if feedback == 0 {
+			continue
+		}
+		for i := range parity {
+			parity[i] ^= mul(generator[i+1], feedback)
+		}
+	}
+}
+
+// Decode corrects the codeword in place and returns its data.
+// Uncorrectable codewords are left as they are, and their data
+// is returned with [ErrUncorrectable], so that a checksum
+// can reject it. Corrected is the number of repaired bytes.
+func Decode(codeword []byte) (data []byte, corrected int, err error) {
+	n := len(codeword)
+	if n <= ParitySize {
+		return nil, 0, ErrShortCodeword
+	}
+	if n > CodewordSize {
+		return nil, 0, errors.New("codeword is longer than 255 bytes")
+	}
+	data = codeword[:n-ParitySize]
+	var s syndromes
+	if s.compute(codeword) {
+		return data, 0, nil
+	}
+
+	locator, errorCount := s.locator()
+	if errorCount > CorrectableErrors {
+		return data, 0, ErrUncorrectable
+	}
+	evaluator := s.evaluator(&locator)
+	var (
+		positions  [CorrectableErrors]int
+		magnitudes [CorrectableErrors]byte
+		found      int
+	)
+	for i := range n {
+		power := n - 1 - i // of the error locator
+		inverse := exp[(255-power)%255]
+		if evaluate(locator[:errorCount+1], inverse) != 0 {
+			continue
+		}
+		if found == errorCount {
+			return data, 0, ErrUncorrectable
+		}
+		derivative := byte(0)
+		for k := 1; k <= errorCount; k += 2 {
+			derivative ^= mul(locator[k], pow(inverse, k-1))
+		}
+		if derivative == 0 {
+			return data, 0, ErrUncorrectable
+		}
+		magnitude := mul(exp[power], div(evaluate(evaluator[:], inverse), derivative))
+		positions[found], magnitudes[found] = i, magnitude
+		found++
+	}
+	if found != errorCount {
+		return data, 0, ErrUncorrectable // roots fall outside the codeword
+	}
+	for k := range found {
+		codeword[positions[k]] ^= magnitudes[k]
+	}
+	if !s.compute(codeword) { // more errors than the locator found
+		for k := range found {
+			codeword[positions[k]] ^= magnitudes[k]
+		}
+		return data, 0, ErrUncorrectable
+	}
+	return data, found, nil
+}
+
+// DecodeAll corrects every codeword of the encoded bytes in place
+// and compacts their data to the beginning of the slice. Data
+// of uncorrectable codewords is kept as it is and returned with
+// [ErrUncorrectable]. Corrected counts the bytes repaired in the
+// other codewords.
+func DecodeAll(b []byte) (data []byte, corrected int, err error) {
+	data = b[:0]
+	for len(b) > 0 {
+		n := min(len(b), CodewordSize)
+		decoded, fixed, cerr := Decode(b[:n])
+		if cerr != nil {
+			err = cerr
+		}
+		data = append(data, decoded...)
+		corrected += fixed
+		b = b[n:]
+	}
+	return data, corrected, err
+}
+
+// Reader decodes a stream of codewords. Errors of the underlying
+// reader, which end the stream, are returned after all the data
+// decoded before them.
+type Reader struct {
+	r         io.Reader
+	codeword  [CodewordSize]byte
+	data      []byte
+	err       error
+	corrected int
+}
+
+// NewReader decodes codewords read from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Reset discards the state of the reader and switches it to r.
+func (r *Reader) Reset(source io.Reader) {
+	*r = Reader{r: source}
+}
+
+// Corrected returns the number of bytes repaired since the last reset.
+func (r *Reader) Corrected() int {
+	return r.corrected
+}
+
+func (r *Reader) Read(b []byte) (n int, err error) {
+	for len(r.data) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		n, r.err = io.ReadFull(r.r, r.codeword[:])
+		if r.err == io.ErrUnexpectedEOF {
+			r.err = io.EOF // short final codeword
+		}
+		if n > 0 {
+			var corrected int
+			r.data, corrected, _ = Decode(r.codeword[:n])
+			r.corrected += corrected
+		}
+	}
+	n = copy(b, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
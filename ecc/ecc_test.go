@@ -0,0 +1,95 @@
+package ecc
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodedSize(t *testing.T) {
+	for n, expected := range map[int]int{
+		0:            0,
+		1:            1 + ParitySize,
+		DataSize:     CodewordSize,
+		DataSize + 1: CodewordSize + 1 + ParitySize,
+	} {
+		if size := EncodedSize(n); size != expected {
+			t.Errorf("%d bytes encode into %d bytes instead of %d", n, size, expected)
+		}
+		if size := len(Encode(nil, make([]byte, n))); size != expected {
+			t.Errorf("%d bytes were encoded into %d bytes instead of %d", n, size, expected)
+		}
+	}
+}
+
+func TestErrorCorrection(t *testing.T) {
+	random := rand.New(rand.NewSource(48))
+	for _, size := range []int{1, 17, DataSize - 1, DataSize} {
+		for errors := range CorrectableErrors + 2 {
+			data := make([]byte, size)
+			random.Read(data)
+			codeword := Encode(nil, data)
+			for _, i := range random.Perm(len(codeword))[:min(errors, len(codeword))] {
+				codeword[i] ^= byte(random.Intn(255) + 1)
+			}
+
+			decoded, corrected, err := Decode(codeword)
+			if errors > CorrectableErrors {
+				if err != ErrUncorrectable {
+					t.Fatalf("%d errors in %d bytes were not detected: %v", errors, size, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%d errors in %d bytes were not corrected: %v", errors, size, err)
+			}
+			if corrected != min(errors, len(codeword)) {
+				t.Fatalf("corrected %d bytes instead of %d", corrected, errors)
+			}
+			if !bytes.Equal(decoded, data) {
+				t.Fatalf("%d errors in %d bytes were corrected into wrong data", errors, size)
+			}
+		}
+	}
+	if _, _, err := Decode(make([]byte, ParitySize)); err != ErrShortCodeword {
+		t.Fatalf("short codeword was decoded: %v", err)
+	}
+}
+
+func TestReader(t *testing.T) {
+	random := rand.New(rand.NewSource(223))
+	data := make([]byte, DataSize*5+100)
+	random.Read(data)
+	encoded := Encode(nil, data)
+	for i := 0; i < len(encoded); i += 50 {
+		encoded[i] ^= 0xff
+	}
+
+	r := NewReader(bytes.NewReader(encoded))
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatal("decoded stream does not match the data")
+	}
+	if r.Corrected() != (len(encoded)+49)/50 {
+		t.Fatalf("corrected %d bytes instead of %d", r.Corrected(), (len(encoded)+49)/50)
+	}
+
+	all, corrected, err := DecodeAll(Encode(nil, data))
+	if !bytes.Equal(all, data) || corrected != 0 || err != nil {
+		t.Fatal("intact codewords were not decoded:", err)
+	}
+
+	encoded = Encode(nil, data)
+	encoded[0] ^= 0xff // corrected
+	for i := range CorrectableErrors + 1 {
+		encoded[CodewordSize+i] ^= 0xff // too many errors in the second codeword
+	}
+	all, corrected, err = DecodeAll(encoded)
+	if err != ErrUncorrectable || corrected != 1 || len(all) != len(data) {
+		t.Fatalf("decoded %d bytes with %d corrected: %v", len(all), corrected, err)
+	}
+}
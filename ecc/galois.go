@@ -0,0 +1,125 @@
+package ecc
+
+// primitive is the polynomial x^8+x^4+x^3+x^2+1 that generates GF(2^8).
+const primitive = 0x11d
+
+var (
+	// exp holds the powers of the generator element twice,
+	// so that sums of two logarithms need no modulo.
+	exp [510]byte
+	log [256]byte
+	// generator is the monic polynomial with roots 1, a, ..., a^31
+	// ordered from the highest degree.
+	generator [ParitySize + 1]byte
+)
+
+func init() {
+	x := 1
+	for i := range 255 {
+		exp[i], exp[i+255] = byte(x), byte(x)
+		log[x] = byte(i)
+		if x <<= 1; x > 0xff {
+			x ^= primitive
+		}
+	}
+	generator[0] = 1
+	for root := range ParitySize { // multiply by (x + a^root)
+		for i := root + 1; i > 0; i-- {
+			generator[i] ^= mul(generator[i-1], exp[root])
+		}
+	}
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return exp[int(log[a])+int(log[b])]
+}
+
+// div divides by a non-zero element.
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return exp[int(log[a])+255-int(log[b])]
+}
+
+func pow(x byte, k int) byte {
+	if k == 0 {
+		return 1
+	}
+	if x == 0 {
+		return 0
+	}
+	return exp[int(log[x])*k%255]
+}
+
+// evaluate computes a polynomial ordered from the lowest degree at x.
+func evaluate(polynomial []byte, x byte) (result byte) {
+	for i := len(polynomial) - 1; i >= 0; i-- {
+		result = mul(result, x) ^ polynomial[i]
+	}
+	return result
+}
+
+// syndromes are the values of a received codeword at the roots
+// of the generator. They are all zero for intact codewords.
+type syndromes [ParitySize]byte
+
+// compute evaluates the codeword, which is ordered from the
+// highest degree. Returns true, if the codeword is intact.
+func (s *syndromes) compute(codeword []byte) (intact bool) {
+	intact = true
+	for j := range s {
+		value := byte(0)
+		for _, b := range codeword {
+			value = mul(value, exp[j]) ^ b
+		}
+		s[j] = value
+		intact = intact && value == 0
+	}
+	return intact
+}
+
+// locator finds the error locator polynomial, ordered from
+// the lowest degree, using the Berlekamp-Massey algorithm.
+// Returns the number of errors, which is its degree.
+func (s *syndromes) locator() (locator [ParitySize + 1]byte, errors int) {
+	var previous, saved [ParitySize + 1]byte
+	locator[0], previous[0] = 1, 1
+	shift, scale := 1, byte(1)
+	for k := range s {
+		discrepancy := s[k]
+		for i := 1; i <= errors; i++ {
+			discrepancy ^= mul(locator[i], s[k-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+		saved = locator
+		coefficient := div(discrepancy, scale)
+		for i := 0; i+shift < len(locator); i++ {
+			locator[i+shift] ^= mul(coefficient, previous[i])
+		}
+		if 2*errors <= k {
+			errors = k + 1 - errors
+			previous, scale, shift = saved, discrepancy, 1
+		} else {
+			shift++
+		}
+	}
+	return locator, errors
+}
+
+// evaluator returns the error evaluator polynomial, the product
+// of the syndromes and the locator truncated to [ParitySize] terms.
+func (s *syndromes) evaluator(locator *[ParitySize + 1]byte) (evaluator [ParitySize]byte) {
+	for i := range evaluator {
+		for k := 0; k <= i; k++ {
+			evaluator[i] ^= mul(s[i-k], locator[k])
+		}
+	}
+	return evaluator
+}
@@ -3,14 +3,15 @@ module github.com/dkotik/gopar3
 go 1.22
 
 require (
-	github.com/klauspost/reedsolomon v1.9.12
+	github.com/klauspost/reedsolomon v1.12.4
 	github.com/urfave/cli/v2 v2.27.1
 	golang.org/x/sync v0.6.0
 )
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
-	github.com/klauspost/cpuid/v2 v2.0.2 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.8 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
+	golang.org/x/sys v0.24.0 // indirect
 )
@@ -10,11 +10,14 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/dkotik/gopar3/swap"
 	"github.com/dkotik/gopar3/telomeres"
-	"github.com/klauspost/reedsolomon"
 	"golang.org/x/sync/errgroup"
 )
 
+// telomereCount is the length of telomere runs between shards.
+const telomereCount = 5
+
 const (
 	ShardLimit      = 1<<(TagBytesForShardOrder*8) - 1
 	ShardBatchLimit = 1<<(TagBytesForShardBatch*8) - 1
@@ -33,10 +36,15 @@ func Inflate(
 	ctx context.Context,
 	destination string,
 	source string,
-	shardQuorum uint8,
-	shardParity uint8,
+	shardQuorum uint16,
+	shardParity uint16,
 	shardSize int,
+	withOptions ...InflateOption,
 ) (err error) {
+	options, err := newInflateOptions(withOptions...)
+	if err != nil {
+		return err
+	}
 	f, err := os.Stat(source)
 	if err != nil {
 		return err
@@ -55,107 +63,266 @@ func Inflate(
 	var (
 		l = &BatchLoader{
 			Quorum:    int(shardQuorum),
-			Shards:    int(shardQuorum + shardParity),
+			Shards:    int(shardQuorum) + int(shardParity),
 			ShardSize: shardSize,
 		}
+		sourceSize = f.Size()
+		batchSize  = int64(l.Quorum * l.ShardSize)
 	)
-	rs, err := reedsolomon.New(
-		l.Quorum, l.Shards-l.Quorum,
-		reedsolomon.WithAutoGoroutines(shardSize),
-	)
-	if err != nil {
+	if batchSize < 1 {
+		return errors.New("shard quorum and size must be greater than zero")
+	}
+	if err = options.codec.validate(l.Quorum, l.Shards-l.Quorum, l.ShardSize); err != nil {
+		return err
+	}
+	if sourceSize > SourceSizeMask {
+		return fmt.Errorf("source is larger than %d bytes", int64(SourceSizeMask))
+	}
+	if l.Pool, err = swap.NewPool(shardSize, options.memoryLimit); err != nil {
 		return err
 	}
+	defer func() {
+		err = errors.Join(err, l.Pool.Close())
+	}()
+	batchCount := int((sourceSize + batchSize - 1) / batchSize)
 
-	tag, err := NewTag(ctx, r, shardQuorum)
-	if err != nil {
+	operation := "inflate"
+	if options.previous != nil {
+		operation = "update"
+	}
+	reporter := newProgressReporter(options.progress, operation)
+	reporter.Update(func(p *Progress) {
+		p.BytesTotal = sourceSize
+		if !options.singlePass {
+			p.BytesTotal *= 2 // checksum pass
+		}
+		p.BatchesTotal = batchCount
+	})
+
+	var tag Tag
+	if previous := options.previous; previous != nil && previous.tag.IsSinglePass() {
+		tag = previous.tag // keep the identity of the updated archive
+		tag.SourceSize = uint64(sourceSize) | SourceSizeSinglePass
+	} else if options.singlePass {
+		tag, err = newSinglePassTag(sourceSize, shardQuorum)
+		if err != nil {
+			return err
+		}
+	} else {
+		tag, err = NewTag(ctx, &progressReader{Reader: r, reporter: reporter}, shardQuorum)
+		if err != nil {
+			return err
+		}
+		if _, err = r.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	tag.setShards(l.Shards)
+	tag.setCodec(options.codec)
+
+	output := destination
+	if f, err = os.Stat(destination); err == nil && f.IsDir() {
+		ext := filepath.Ext(source)
+		base := strings.TrimSuffix(filepath.Base(source), ext)
+		output = filepath.Join(
+			destination,
+			fmt.Sprintf(`%s%x%s.gopar3`, base, tag.SourceCRC, ext),
+		)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
-	if _, err = r.Seek(0, io.SeekStart); err != nil {
+	// updates replace the archive they were made from
+	w, err := OpenAtomicFile(output, options.overwrite || options.previous != nil)
+	if err != nil {
 		return err
 	}
+	defer func() {
+		err = w.Finish(err)
+	}()
 
-	var w io.Writer
-	{ // create io.Writer
-		f, err = os.Stat(destination)
-		if err == os.ErrNotExist {
-			w, err = os.Create(destination)
-			if err != nil {
-				return err
-			}
-		} else if err == nil && f.IsDir() {
-			ext := filepath.Ext(source)
-			base := strings.TrimSuffix(filepath.Base(source), ext)
-			w, err = os.Create(filepath.Join(
-				destination,
-				fmt.Sprintf(`%s%x%s.gopar3`, base, tag.SourceCRC, ext),
-			))
+	wtlm, err := telomeres.NewEncoder(w, telomereCount, options.telomeres...)
+	if err != nil {
+		return err
+	}
+	var previous io.ReaderAt
+	if options.previous != nil {
+		if wtlm.Marks() != options.previous.marks ||
+			wtlm.Framing() != options.previous.framing ||
+			options.innerCode != options.previous.innerCode {
+			options.previous = nil // framing differs, nothing can be copied
+		} else {
+			archive, err := os.Open(options.previous.path)
 			if err != nil {
 				return err
 			}
-		} else {
+			defer func() {
+				err = errors.Join(err, archive.Close())
+			}()
+			previous = archive
+		}
+	}
+	// continue after the last intact batch of an interrupted inflate,
+	// single pass tags never match, because they are random
+	resumed, offset, err := resumePoint(ctx, w, tag, l.Shards, l.ShardSize, wtlm.Marks(), wtlm.Framing(), options.innerCode)
+	if err != nil {
+		return err
+	}
+	if err = w.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err = w.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	reporter.Update(func(p *Progress) {
+		p.BytesRead += min(int64(resumed)*batchSize, sourceSize)
+		p.BatchesWritten = resumed
+	})
+	if resumed == 0 {
+		if err = writeHeaders(wtlm, Header{
+			Version:   HeaderFormatVersion,
+			Telomeres: telomereCount,
+			Marks:     wtlm.Marks(),
+			Framing:   wtlm.Framing(),
+			Codec:     options.codec,
+			InnerCode: options.innerCode,
+			Quorum:    shardQuorum,
+			Parity:    shardParity,
+			ShardSize: uint32(shardSize),
+			Created:   options.created,
+		}); err != nil {
+			return err
+		}
+	}
+	batchTag := tag
+	batchTag.ShardBatch = uint16(resumed)
+	tagger := NewSequentialTagger(batchTag, l.Shards)
+	shardWriter, err := newWriter(wtlm, tagger, options.innerCode)
+	if err != nil {
+		return err
+	}
+	crc := crc32.New(castagnoliTable)
+	remaining := sourceSize
+	if options.singlePass && resumed > 0 {
+		// checksum the data of resumed batches
+		prefix := min(int64(resumed)*batchSize, sourceSize)
+		if _, err = io.Copy(crc, io.NewSectionReader(r, 0, prefix)); err != nil {
 			return err
 		}
+		remaining -= prefix
 	}
 
+	tokens := make(chan struct{}, 2*options.jobs)
+	forLoading := make(chan int)
+	forWriting := make(chan orderedBatch, options.jobs)
 	wg, ctx := errgroup.WithContext(ctx)
-	batches := make(chan [][]byte, 4)
-	wg.Go(func() (err error) {
-		defer close(batches)
-		var batch [][]byte
-		for {
-			batch, _, err = l.Load(r)
-			if err != nil {
-				// panic(fmt.Sprintf("%+v", batch))
-				if err == io.EOF {
-					return nil
+	wg.Go(func() error {
+		return dispatchBatches(ctx, batchCount-resumed, tokens, forLoading)
+	})
+
+	wg.Go(func() error {
+		defer close(forWriting)
+		workers, ctx := errgroup.WithContext(ctx)
+		for range options.jobs {
+			workers.Go(func() (err error) {
+				rs, err := options.codec.newEncoder(l.Quorum, l.Shards-l.Quorum)
+				if err != nil {
+					return err
 				}
-				return err
-			}
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case batches <- batch:
-			}
+				// positional reads share the source handle
+				section := io.NewSectionReader(r, 0, sourceSize)
+				var (
+					batch  [][]byte
+					loaded int
+				)
+				for i := range forLoading {
+					if _, err = l.Seek(section, resumed+i); err != nil {
+						return err
+					}
+					if batch, loaded, err = l.Load(section); err != nil {
+						return err
+					}
+					reporter.Update(func(p *Progress) {
+						p.BytesRead += int64(loaded)
+					})
+					ordered := orderedBatch{index: i, shards: batch}
+					if unchanged, ok := options.previous.unchanged(resumed+i, batch[:l.Quorum]); !ok {
+						if err = rs.Reconstruct(batch); err != nil {
+							return err
+						}
+					} else if unchanged.isVerbatim(tag) {
+						ordered.unchanged = &unchanged
+						ordered.copied = true
+					} else if err = unchanged.loadParity(ctx, previous, batch); err == nil {
+						ordered.copied = true // tags are written again
+					} else if err = rs.Reconstruct(batch); err != nil {
+						return err
+					}
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case forWriting <- ordered:
+					}
+				}
+				return nil
+			})
 		}
+		return workers.Wait()
 	})
 
-	batchesWithParity := make(chan [][]byte, 4)
-	wg.Go(func() (err error) {
-		defer close(batchesWithParity)
-		for batch := range batches {
-			if err = rs.Reconstruct(batch); err != nil {
-				return err
+	wg.Go(func() error {
+		return writeInOrder(forWriting, tokens, func(ordered orderedBatch) (err error) {
+			batch := ordered.shards
+			defer l.Release(batch)
+			if unchanged := ordered.unchanged; unchanged != nil {
+				if err = copyBatch(w, wtlm, tagger, previous, unchanged, l.Shards); err != nil {
+					return err
+				}
+			} else {
+				for _, shard := range batch {
+					if _, err = shardWriter.Write(shard); err != nil {
+						return err
+					}
+				}
 			}
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case batchesWithParity <- batch:
+			reporter.Update(func(p *Progress) {
+				p.BatchesWritten++
+				if ordered.copied {
+					p.BatchesCopied++
+				}
+			})
+			if options.singlePass {
+				for _, shard := range batch[:l.Quorum] {
+					data := shard[:min(int64(len(shard)), remaining)]
+					if _, err = crc.Write(data); err != nil {
+						return err
+					}
+					remaining -= int64(len(data))
+				}
 			}
-		}
-		return nil
+			return nil
+		})
 	})
-
-	wtlm, err := telomeres.NewEncoder(w, 5)
-	if err != nil {
+	if err = wg.Wait(); err != nil {
 		return err
 	}
-	shardWriter, err := NewWriter(wtlm, NewSequentialTagger(tag, shardQuorum+shardParity))
+	if !options.singlePass {
+		reporter.Finish()
+		return nil
+	}
+
+	// trailers are as resilient as the batches
+	trailerWriter, err := newWriter(wtlm, newTrailerTagger(tag), options.innerCode)
 	if err != nil {
 		return err
 	}
-	wg.Go(func() (err error) {
-		for batch := range batchesWithParity {
-			// log.Printf("batch size is %d", len(batch))
-			for _, shard := range batch {
-				if _, err = shardWriter.Write(shard); err != nil {
-					return err
-				}
-			}
+	trailer := Trailer{SourceCRC: crc.Sum32(), SourceSize: uint64(sourceSize)}.Bytes()
+	for range int(shardParity) + 1 {
+		if _, err = trailerWriter.Write(trailer); err != nil {
+			return err
 		}
-		return nil
-	})
-	return wg.Wait()
+	}
+	reporter.Finish()
+	return nil
 }
 
 func CastagnoliSum(ctx context.Context, r io.Reader) (uint32, error) {
@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"context"
 	"hash/crc32"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
@@ -27,6 +31,151 @@ func TestInflate(t *testing.T) {
 	}
 }
 
+func TestInflateWithSyncMarkers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	destination := t.TempDir()
+	err := Inflate(
+		ctx,
+		destination,
+		"README.md",
+		5,
+		3,
+		64,
+		WithSyncMarkers(16),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputs, err := filepath.Glob(filepath.Join(destination, "*.gopar3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, _ := NewIndex(ctx, outputs...)
+	if len(index.Files) != 1 {
+		t.Fatalf("index contains %d files instead of one", len(index.Files))
+	}
+	for _, file := range index.Files {
+		for _, shard := range file.Shards {
+			if len(shard.SubChunks) != (TagBytesForCRC+TagSize+64+15)/16 {
+				t.Fatalf("shard %s has %d sub-chunks", shard.Tag, len(shard.SubChunks))
+			}
+			for _, subChunk := range shard.SubChunks {
+				if !subChunk.Intact {
+					t.Fatalf("shard %s sub-chunk %+v is damaged", shard.Tag, subChunk)
+				}
+			}
+		}
+	}
+}
+
+func TestInflateWithTelomereMarks(t *testing.T) {
+	testInflateAndRestore(t, WithTelomereMarks('#', '~'))
+}
+
+func TestInflateWithByteStuffing(t *testing.T) {
+	testInflateAndRestore(t, WithByteStuffing())
+	testInflateAndRestore(t, WithByteStuffing(), WithTelomereMarks('#', '~'))
+}
+
+func testInflateAndRestore(t *testing.T, withOptions ...InflateOption) {
+	t.Helper()
+	testInflateAndRestoreWithOptions(t, roundTrip{inflate: withOptions})
+}
+
+// testFixtureSize is the size of the source inflated by
+// [testInflateAndRestoreWithOptions], which spans many batches.
+const testFixtureSize = 8 << 10
+
+// testFixture returns the same pseudo-random bytes on every call.
+func testFixture() []byte {
+	b := make([]byte, testFixtureSize)
+	rand.New(rand.NewSource(testFixtureSize)).Read(b)
+	return b
+}
+
+// roundTrip configures [testInflateAndRestoreWithOptions]. Its hooks
+// let tests damage the archive and check or change the index.
+type roundTrip struct {
+	inflate []InflateOption
+	restore []RestoreOption
+	// progress receives the reports of every step, if set
+	progress ProgressFunc
+	// sources makes the scanned sources out of the archive,
+	// which is scanned alone, if nil
+	sources func(t *testing.T, archive string) []string
+	// scan returns the options for scanning the sources
+	scan func(sources []string) []IndexOption
+	// prepare runs before restoration
+	prepare func(t *testing.T, index *Index, sources []string)
+	// unrecoverable files must fail to restore
+	unrecoverable bool
+}
+
+// testInflateAndRestoreWithOptions inflates the [testFixture] into
+// an archive of five data and three parity shards of 64 bytes,
+// scans it, and restores the file. Files without healthy shards,
+// which damaged tags split off, are not restored.
+func testInflateAndRestoreWithOptions(t *testing.T, c roundTrip) Index {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	directory := t.TempDir()
+	expected := testFixture()
+	source := filepath.Join(directory, "fixture.bin")
+	if err := os.WriteFile(source, expected, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(directory, "archive.gopar3")
+	inflate := append(slices.Clone(c.inflate), WithInflateProgress(c.progress))
+	if err := Inflate(ctx, archive, source, 5, 3, 64, inflate...); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := []string{archive}
+	if c.sources != nil {
+		sources = c.sources(t, archive)
+	}
+	options := []IndexOption{WithIndexProgress(c.progress)}
+	if c.scan != nil {
+		options = append(options, c.scan(sources)...)
+	}
+	index, err := ScanIndex(ctx, sources, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.prepare != nil {
+		c.prepare(t, &index, sources)
+	}
+
+	restorable := 0
+	for _, file := range index.Files {
+		if file.ShardSize == 0 {
+			continue
+		}
+		restorable++
+		b := &bytes.Buffer{}
+		err = Restore(ctx, b, file, append(slices.Clone(c.restore), WithRestoreProgress(c.progress))...)
+		if c.unrecoverable {
+			if err == nil || file.Error == "" {
+				t.Fatal("restored a file without enough readable shards")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b.Bytes(), expected) {
+			t.Fatal("restored file does not match the original")
+		}
+	}
+	if restorable != 1 {
+		t.Fatalf("index contains %d restorable files instead of one", restorable)
+	}
+	return index
+}
+
 func TestIdenticalQuorumShardsWithDifferentParity(t *testing.T) {
 	quorum := [][]byte{
 		[]byte("aaa"),
@@ -0,0 +1,269 @@
+package gopar3
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/dkotik/gopar3/ecc"
+	"github.com/dkotik/gopar3/telomeres"
+)
+
+const (
+	// HeaderMagic begins every [Header] chunk. It follows the first
+	// [telomereCount] bytes of an archive, or one more with byte
+	// stuffing, which lets the file command recognize archives.
+	// See gopar3.magic.
+	HeaderMagic = "GOPAR3"
+
+	// HeaderFormatVersion is incremented whenever the layout
+	// of shards changes in a way that older versions cannot read.
+	HeaderFormatVersion = 1
+
+	// HeaderCopies is the number of header chunks written at the
+	// start of every archive, so that damage to one of them does
+	// not hide the parameters.
+	HeaderCopies = 3
+
+	// HeaderBeginVersion and the offsets that follow it locate the
+	// fields of a header chunk after [HeaderMagic]. Numbers are
+	// big-endian, and the creation time is in Unix seconds.
+	HeaderBeginVersion   = len(HeaderMagic)
+	HeaderBeginTelomeres = HeaderBeginVersion + 1
+	HeaderBeginMark      = HeaderBeginTelomeres + 1
+	HeaderBeginEscape    = HeaderBeginMark + 1
+	HeaderBeginFraming   = HeaderBeginEscape + 1
+	HeaderBeginCodec     = HeaderBeginFraming + 1
+	HeaderBeginFlags     = HeaderBeginCodec + 1
+	HeaderBeginQuorum    = HeaderBeginFlags + 1
+	HeaderBeginParity    = HeaderBeginQuorum + 2
+	HeaderBeginShardSize = HeaderBeginParity + 2
+	HeaderBeginCreated   = HeaderBeginShardSize + 4
+	HeaderBeginCRC       = HeaderBeginCreated + 8
+
+	// HeaderSize is the length of a header chunk, which ends with
+	// the Castagnoli checksum of the preceding bytes.
+	HeaderSize = HeaderBeginCRC + TagBytesForCRC
+
+	// HeaderFlagInnerCode is set for archives written with [WithInnerCode].
+	HeaderFlagInnerCode = 1 << 0
+)
+
+// Header describes the archive that follows it. It is not needed
+// for restoration, because every shard carries its own tag, but lets
+// people and tools identify archives and their parameters without
+// decoding shards. [NewIndex] takes the framing from an intact header
+// before probing the shards, and the codec and shard size, where they
+// agree with the tags. [Reader] skips header chunks.
+type Header struct {
+	Version uint8
+	// Telomeres is the length of telomere runs between chunks.
+	Telomeres uint8
+	Marks     telomeres.Marks
+	Framing   telomeres.Framing
+	Codec     Codec
+	InnerCode bool
+	Quorum    uint16
+	Parity    uint16
+	ShardSize uint32
+	Created   time.Time
+}
+
+// NewHeaderFromBytes decodes a header chunk and verifies its checksum.
+// Headers of a newer format version are rejected.
+func NewHeaderFromBytes(b []byte) (h Header, err error) {
+	if len(b) != HeaderSize || !bytes.HasPrefix(b, []byte(HeaderMagic)) {
+		return h, errors.New("not a header chunk")
+	}
+	if sum := binary.BigEndian.Uint32(b[HeaderBeginCRC:]); sum != crc32.Checksum(b[:HeaderBeginCRC], castagnoliTable) {
+		return h, errors.New("corrupted header: Castagnoli CRC32 sum does not match")
+	}
+	if version := b[HeaderBeginVersion]; version > HeaderFormatVersion {
+		// the parameters may no longer mean the same
+		return h, fmt.Errorf("header format version %d is newer than %d", version, HeaderFormatVersion)
+	}
+	return Header{
+		Version:   b[HeaderBeginVersion],
+		Telomeres: b[HeaderBeginTelomeres],
+		Marks:     telomeres.Marks{Mark: b[HeaderBeginMark], Escape: b[HeaderBeginEscape]},
+		Framing:   telomeres.Framing(b[HeaderBeginFraming]),
+		Codec:     Codec(b[HeaderBeginCodec]),
+		InnerCode: b[HeaderBeginFlags]&HeaderFlagInnerCode != 0,
+		Quorum:    binary.BigEndian.Uint16(b[HeaderBeginQuorum:]),
+		Parity:    binary.BigEndian.Uint16(b[HeaderBeginParity:]),
+		ShardSize: binary.BigEndian.Uint32(b[HeaderBeginShardSize:]),
+		Created:   time.Unix(int64(binary.BigEndian.Uint64(b[HeaderBeginCreated:])), 0).UTC(),
+	}, nil
+}
+
+// Bytes encodes the header into binary format.
+func (h Header) Bytes() []byte {
+	b := make([]byte, HeaderSize)
+	copy(b, HeaderMagic)
+	b[HeaderBeginVersion] = h.Version
+	b[HeaderBeginTelomeres] = h.Telomeres
+	b[HeaderBeginMark] = h.Marks.Mark
+	b[HeaderBeginEscape] = h.Marks.Escape
+	b[HeaderBeginFraming] = byte(h.Framing)
+	b[HeaderBeginCodec] = byte(h.Codec)
+	if h.InnerCode {
+		b[HeaderBeginFlags] |= HeaderFlagInnerCode
+	}
+	binary.BigEndian.PutUint16(b[HeaderBeginQuorum:], h.Quorum)
+	binary.BigEndian.PutUint16(b[HeaderBeginParity:], h.Parity)
+	binary.BigEndian.PutUint32(b[HeaderBeginShardSize:], h.ShardSize)
+	binary.BigEndian.PutUint64(b[HeaderBeginCreated:], uint64(h.Created.Unix()))
+	binary.BigEndian.PutUint32(b[HeaderBeginCRC:], crc32.Checksum(b[:HeaderBeginCRC], castagnoliTable))
+	return b
+}
+
+func (h Header) String() string {
+	inner := ""
+	if h.InnerCode {
+		inner = " with inner code"
+	}
+	return fmt.Sprintf(
+		"gopar3 format %d, %s %d+%d shards of %d bytes%s, created %s",
+		h.Version, h.Codec, h.Quorum, h.Parity, h.ShardSize, inner,
+		h.Created.Format(time.RFC3339),
+	)
+}
+
+// isHeaderChunk returns true for decoded chunks that begin with
+// [HeaderMagic] and have the size of a header, even if damaged.
+func isHeaderChunk(b []byte) bool {
+	return len(b) == HeaderSize && bytes.HasPrefix(b, []byte(HeaderMagic))
+}
+
+// writeHeaders writes [HeaderCopies] header chunks. The last one is
+// closed by the telomeres that open the first shard.
+func writeHeaders(w *telomeres.Encoder, h Header) error {
+	b := h.Bytes()
+	if h.InnerCode {
+		b = ecc.Encode(nil, b)
+	}
+	for range HeaderCopies {
+		if _, err := w.Cut(); err != nil {
+			return err
+		}
+		n, err := w.Write(b)
+		if err != nil {
+			return err
+		}
+		if n != len(b) {
+			return io.ErrShortWrite
+		}
+	}
+	return nil
+}
+
+// SourceHeader is a [Header] found in a source.
+type SourceHeader struct {
+	Source    string
+	FirstByte int64
+	Header
+}
+
+// fileHeader returns the [Header] that precedes a healthy shard
+// of the file in its source and records the same quorum.
+// [Index.Headers] must be sorted.
+func (i Index) fileHeader(f *File) (Header, bool) {
+	for _, shard := range f.Shards {
+		if shard.Error != "" {
+			continue
+		}
+		var preceding *SourceHeader
+		for j := range i.Headers {
+			if h := &i.Headers[j]; h.Source == shard.Source && h.FirstByte < shard.FirstByte {
+				preceding = h
+			}
+		}
+		if preceding != nil && preceding.Quorum == shard.Tag.ShardQuorum {
+			return preceding.Header, true
+		}
+	}
+	return Header{}, false
+}
+
+// headerSearchSize is the number of leading bytes of a source
+// searched for header chunks, which is enough for every copy
+// even if all of their bytes are escaped.
+const headerSearchSize = 4 << 10
+
+// readHeader finds the first intact [Header] at the start of the
+// source and rewinds it.
+func readHeader(ctx context.Context, source *skippingReader) (h Header, found bool, err error) {
+	b, err := io.ReadAll(io.LimitReader(source, headerSearchSize))
+	if err != nil {
+		return h, false, err
+	}
+	if _, err = source.Seek(0, io.SeekStart); err != nil {
+		return h, false, err
+	}
+	h, found = findHeader(ctx, source.source, b)
+	return h, found, nil
+}
+
+// findHeader decodes the first intact [Header] among the bytes. An
+// archive begins with a run of its mark byte. The escape byte is not
+// known until the header is decoded, but the mark recorded in the
+// header is escaped, so only the bytes before a lone mark are tried.
+// Byte stuffing does not use the escape byte.
+func findHeader(ctx context.Context, source string, b []byte) (Header, bool) {
+	if len(b) == 0 {
+		return Header{}, false
+	}
+	mark := b[0]
+	stuffing := telomeres.Marks{Mark: mark, Escape: telomeres.Escape}
+	if stuffing.Validate() != nil {
+		stuffing.Escape = telomeres.Mark
+	}
+	if h, ok := decodeHeader(ctx, source, b, stuffing, telomeres.FramingStuffing); ok {
+		return h, true
+	}
+	tried := make(map[byte]bool)
+	for i := 1; i < len(b); i++ {
+		marks := telomeres.Marks{Mark: mark, Escape: b[i-1]}
+		if b[i] != mark || tried[marks.Escape] || marks.Validate() != nil {
+			continue
+		}
+		tried[marks.Escape] = true
+		if h, ok := decodeHeader(ctx, source, b, marks, telomeres.FramingEscape); ok {
+			return h, true
+		}
+	}
+	return Header{}, false
+}
+
+// decodeHeader returns the first header decoded from the bytes
+// with or without the inner code that records the marks and framing
+// it was decoded with.
+func decodeHeader(ctx context.Context, source string, b []byte, marks telomeres.Marks, framing telomeres.Framing) (Header, bool) {
+	for _, innerCode := range [...]bool{false, true} {
+		r := NewReader(source, bytes.NewReader(b),
+			telomeres.WithDecoderMarks(marks),
+			telomeres.WithDecoderFraming(framing),
+		)
+		r.InnerCode = innerCode
+		// damaged copies are read as shards
+		for range HeaderCopies {
+			_, err := r.NextShard(ctx, io.Discard)
+			for _, found := range r.Headers {
+				h := found.Header
+				if h.Framing == framing && h.InnerCode == innerCode && h.Marks.Mark == marks.Mark &&
+					(framing == telomeres.FramingStuffing || h.Marks.Escape == marks.Escape) {
+					return h, true
+				}
+			}
+			if err != nil || len(r.Headers) > 0 {
+				break
+			}
+		}
+	}
+	return Header{}, false
+}
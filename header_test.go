@@ -0,0 +1,305 @@
+package gopar3
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dkotik/gopar3/telomeres"
+)
+
+func TestHeaderEncoding(t *testing.T) {
+	header := Header{
+		Version:   HeaderFormatVersion,
+		Telomeres: telomereCount,
+		Marks:     telomeres.DefaultMarks,
+		Framing:   telomeres.FramingStuffing,
+		Codec:     CodecLeopard,
+		InnerCode: true,
+		Quorum:    1000,
+		Parity:    800,
+		ShardSize: 1 << 20,
+		Created:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	b := header.Bytes()
+	decoded, err := NewHeaderFromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != header {
+		t.Fatalf("decoded %+v instead of %+v", decoded, header)
+	}
+	b[HeaderBeginQuorum] ^= 0x01
+	if _, err = NewHeaderFromBytes(b); err == nil {
+		t.Fatal("damaged header was decoded")
+	}
+	if !isHeaderChunk(b) {
+		t.Fatal("damaged header is not recognized as a header chunk")
+	}
+
+	header.Version = HeaderFormatVersion + 1
+	if _, err = NewHeaderFromBytes(header.Bytes()); err == nil {
+		t.Fatal("header of a newer format version was decoded")
+	}
+}
+
+func TestInflateWritesHeaders(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for name, c := range map[string]struct {
+		options []InflateOption
+		offset  int
+	}{
+		"escape":   {offset: telomereCount},
+		"stuffing": {options: []InflateOption{WithByteStuffing()}, offset: telomereCount + 1},
+		"inner":    {options: []InflateOption{WithInnerCode()}, offset: telomereCount},
+	} {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "archive.gopar3")
+			options := append([]InflateOption{WithCreationTime(created)}, c.options...)
+			if err := Inflate(ctx, archive, "README.md", 3, 2, 64, options...); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if magic := string(b[c.offset : c.offset+len(HeaderMagic)]); magic != HeaderMagic {
+				t.Fatalf("archive begins with %q instead of the magic", b[:c.offset+len(HeaderMagic)])
+			}
+
+			index, err := NewIndex(ctx, archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(index.Files) != 1 {
+				t.Fatalf("header chunks were indexed as %d files", len(index.Files))
+			}
+			if len(index.Headers) != 1 {
+				t.Fatalf("found %d headers instead of one", len(index.Headers))
+			}
+			header := index.Headers[0]
+			if header.Version != HeaderFormatVersion ||
+				header.Quorum != 3 || header.Parity != 2 || header.ShardSize != 64 ||
+				!header.Created.Equal(created) {
+				t.Fatalf("unexpected header: %s", header.Header)
+			}
+			for _, f := range index.Files {
+				if header.Framing != f.Shards[0].Framing || header.InnerCode != f.Shards[0].InnerCode {
+					t.Fatalf("header %s does not match the shards", header.Header)
+				}
+			}
+		})
+	}
+}
+
+func TestScanReadsMarksFromHeader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	directory := t.TempDir()
+	// long runs of a byte that never appears alone look like telomeres
+	original := bytes.Repeat([]byte("abbbbbbb"), 8192)
+	source := filepath.Join(directory, "runs.bin")
+	if err := os.WriteFile(source, original, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(directory, "archive.gopar3")
+	if err := Inflate(ctx, archive, source, 4, 2, 4096, WithTelomereMarks('#', '~')); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marks := telomeres.Marks{Mark: '#', Escape: '~'}
+	if guess, _ := telomeres.DetectMarks(bytes.NewReader(b)); guess == marks {
+		t.Fatal("frequency analysis guessed the marks, so the header is not tested")
+	}
+	if restored := restoreArchive(t, ctx, archive); !bytes.Equal(restored.data, original) {
+		t.Fatal("restored file does not match the original")
+	}
+
+	// without intact headers, the marks must be given
+	damaged := bytes.ReplaceAll(b, []byte(HeaderMagic), []byte("gopar3"))
+	if err = os.WriteFile(archive, damaged, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	index, err := ScanIndex(ctx, []string{archive}, WithIndexMarks(marks.Mark, marks.Escape))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restorable := 0
+	for _, file := range index.Files {
+		if file.Error != "" {
+			continue // the damaged headers
+		}
+		restorable++
+		restored := &bytes.Buffer{}
+		if err = Restore(ctx, restored, file); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(restored.Bytes(), original) {
+			t.Fatal("restored file does not match the original")
+		}
+	}
+	if restorable != 1 {
+		t.Fatalf("found %d restorable files instead of one", restorable)
+	}
+}
+
+func TestScanReadsFramingFromHeader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	archive := filepath.Join(t.TempDir(), "archive.gopar3")
+	if err := Inflate(ctx, archive, "README.md", 3, 2, 64, WithByteStuffing(), WithInnerCode()); err != nil {
+		t.Fatal(err)
+	}
+	index, err := NewIndex(ctx, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shards []*Shard
+	for _, file := range index.Files {
+		shards = append(shards, file.Shards...)
+	}
+	slices.SortFunc(shards, func(a, b *Shard) int {
+		return cmp.Compare(a.FirstByte, b.FirstByte)
+	})
+	b, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every shard probed for the framing is lost
+	for _, shard := range shards[:framingProbeShards] {
+		copy(b[shard.FirstByte:shard.LastByte], bytes.Repeat([]byte{'x'}, int(shard.LastByte-shard.FirstByte)))
+	}
+	healthy := func(b []byte) (healthy int) {
+		if err = os.WriteFile(archive, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		index, err := NewIndex(ctx, archive)
+		if err != nil {
+			return 0
+		}
+		for _, file := range index.Files {
+			for _, shard := range file.Shards {
+				if shard.Error == "" {
+					healthy++
+				}
+			}
+		}
+		return healthy
+	}
+	withoutHeaders := bytes.Clone(b)
+	copy(withoutHeaders[8:shards[0].FirstByte-8], bytes.Repeat([]byte{'x'}, int(shards[0].FirstByte-16)))
+	if healthy(withoutHeaders) > 0 {
+		t.Fatal("probing found the framing, so the header is not tested")
+	}
+	if found := healthy(b); found != len(shards)-framingProbeShards {
+		t.Fatalf("found %d healthy shards instead of %d", found, len(shards)-framingProbeShards)
+	}
+}
+
+func TestNormalizePrefersHeader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	archive := filepath.Join(t.TempDir(), "archive.gopar3")
+	if err := Inflate(ctx, archive, "README.md", 3, 2, 64, WithCodec(CodecLeopard)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		header    func(*Header)
+		shardSize int64
+	}{
+		{name: "header size of some shards", header: func(*Header) {}, shardSize: 64},
+		{name: "header size of no shard", header: func(h *Header) { h.ShardSize = 63 }, shardSize: 65},
+		{name: "codec of extended tags", header: func(h *Header) { h.Codec = CodecReedSolomon }, shardSize: 64},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			index, err := NewIndex(ctx, archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range index.Headers {
+				c.header(&index.Headers[i].Header)
+			}
+			for _, file := range index.Files {
+				for i, shard := range file.Shards {
+					if i%3 > 0 { // most shards claim another size
+						shard.Size++
+					}
+				}
+			}
+			if err = index.Normalize(); err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range index.Files {
+				if file.Codec != CodecLeopard || file.ShardSize != c.shardSize {
+					t.Fatalf("normalized %s shards of %d bytes", file.Codec, file.ShardSize)
+				}
+			}
+		})
+	}
+}
+
+func TestFindHeader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	custom := telomeres.Marks{Mark: '#', Escape: '~'}
+	for name, c := range map[string]struct {
+		options []InflateOption
+		header  Header
+	}{
+		"default": {header: Header{Marks: telomeres.DefaultMarks}},
+		"marks": {
+			options: []InflateOption{WithTelomereMarks(custom.Mark, custom.Escape)},
+			header:  Header{Marks: custom},
+		},
+		"stuffing": {
+			options: []InflateOption{WithTelomereMarks(custom.Mark, custom.Escape), WithByteStuffing()},
+			header:  Header{Marks: custom, Framing: telomeres.FramingStuffing},
+		},
+		"inner": {
+			options: []InflateOption{WithTelomereMarks(custom.Escape, custom.Mark), WithInnerCode()},
+			header:  Header{Marks: telomeres.Marks{Mark: custom.Escape, Escape: custom.Mark}, InnerCode: true},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "archive.gopar3")
+			created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			options := append([]InflateOption{WithCreationTime(created)}, c.options...)
+			if err := Inflate(ctx, archive, "README.md", 3, 2, 64, options...); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b[bytes.Index(b, []byte(HeaderMagic))] ^= 0xff // the first copy is damaged
+			header, ok := findHeader(ctx, archive, b[:headerSearchSize])
+			if !ok {
+				t.Fatal("header was not found")
+			}
+			expected := c.header
+			expected.Version = HeaderFormatVersion
+			expected.Telomeres = telomereCount
+			expected.Quorum = 3
+			expected.Parity = 2
+			expected.ShardSize = 64
+			expected.Created = created
+			if header != expected {
+				t.Fatalf("found %+v instead of %+v", header, expected)
+			}
+		})
+	}
+}
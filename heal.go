@@ -0,0 +1,244 @@
+package gopar3
+
+import (
+	"context"
+	"encoding/hex"
+	"hash/crc32"
+	"math/bits"
+	"slices"
+)
+
+// Realignment describes a single-byte correction that restores
+// a shard shortened or lengthened by damage to its original size.
+type Realignment struct {
+	// Offset is the position of the correction in the shard
+	// data that follows the checksum.
+	Offset int
+	// Inserted is true when the byte was restored into the data
+	// instead of being deleted from it.
+	Inserted bool
+	Byte     byte
+}
+
+// apply corrects shard data that follows the checksum.
+func (r *Realignment) apply(b []byte) []byte {
+	if r.Inserted {
+		return slices.Insert(b, r.Offset, r.Byte)
+	}
+	return slices.Delete(b, r.Offset, r.Offset+1)
+}
+
+// realign searches for a single-byte insertion or deletion
+// that makes shard data match the Castagnoli sum and the given size.
+// Each candidate is derived from the previous one using the linearity
+// of the checksum, so the search takes linear time.
+func realign(b []byte, sum uint32, size int) (*Realignment, bool) {
+	switch len(b) {
+	case size + 1:
+		return realignDeletion(b, sum)
+	case size - 1:
+		return realignInsertion(b, sum)
+	default:
+		return nil, false
+	}
+}
+
+// crcBasis holds the checksum register contributions of each bit
+// of a byte followed by a number of zero bytes.
+type crcBasis [8]uint32
+
+func newCRCBasis() (basis crcBasis) {
+	for bit := range basis {
+		basis[bit] = castagnoliTable[1<<bit]
+	}
+	return basis
+}
+
+// advance shifts the contributions by one more zero byte.
+func (c *crcBasis) advance() {
+	for bit, r := range c {
+		c[bit] = castagnoliTable[byte(r)] ^ (r >> 8)
+	}
+}
+
+// of returns the register contribution of a byte.
+func (c *crcBasis) of(v byte) (r uint32) {
+	for bit := range c {
+		if v&(1<<bit) != 0 {
+			r ^= c[bit]
+		}
+	}
+	return r
+}
+
+func realignDeletion(b []byte, sum uint32) (*Realignment, bool) {
+	last := len(b) - 1
+	if last < 0 {
+		return nil, false
+	}
+	// candidate without the last byte
+	candidate := crc32.Checksum(b[:last], castagnoliTable)
+	if candidate == sum {
+		return &Realignment{Offset: last, Byte: b[last]}, true
+	}
+	// removing byte i instead of byte i+1 changes
+	// only the byte at position i of the candidate
+	basis := newCRCBasis()
+	for i := last - 1; i >= 0; i-- {
+		candidate ^= basis.of(b[i] ^ b[i+1])
+		if candidate == sum {
+			return &Realignment{Offset: i, Byte: b[i]}, true
+		}
+		basis.advance()
+	}
+	return nil, false
+}
+
+func realignInsertion(b []byte, sum uint32) (*Realignment, bool) {
+	var (
+		// candidate with a zero byte inserted at the end
+		candidate = crc32.Update(
+			crc32.Checksum(b, castagnoliTable),
+			castagnoliTable, []byte{0},
+		)
+		basis    = newCRCBasis()
+		previous crcBasis
+	)
+	for i := len(b); i >= 0; i-- {
+		if i < len(b) {
+			// moving the zero byte from i+1 to i swaps
+			// it with the data byte at position i
+			candidate ^= basis.of(b[i]) ^ previous.of(b[i])
+		}
+		if x, ok := basis.solve(candidate ^ sum); ok {
+			return &Realignment{Offset: i, Inserted: true, Byte: x}, true
+		}
+		previous = basis
+		basis.advance()
+	}
+	return nil, false
+}
+
+// solve finds the byte with the given register contribution.
+func (c *crcBasis) solve(r uint32) (byte, bool) {
+	var (
+		v    byte
+		gray uint32
+		bit  int
+	)
+	if r == 0 {
+		return 0, true
+	}
+	for i := uint(1); i < 256; i++ {
+		// walk all bytes in Gray code order changing one bit at a time
+		bit = bits.TrailingZeros(i)
+		v ^= 1 << bit
+		gray ^= c[bit]
+		if gray == r {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// adoptStrayShards moves damaged shards, whose size was changed by an
+// insertion or a deletion, into the [File] with the same tag that holds
+// the most healthy shards. Shards of different sizes are grouped apart
+// by [Shard.Differentiator], because their size is part of it.
+func (i Index) adoptStrayShards() {
+	parents := make(map[string]*File)
+	healthy := make(map[*File]int)
+	for _, f := range i.Files {
+		for _, shard := range f.Shards {
+			if shard.Error == "" {
+				healthy[f]++
+			}
+		}
+		if len(f.Shards) == 0 || healthy[f] == 0 {
+			continue
+		}
+		tag := hex.EncodeToString(f.Shards[0].Tag.Bytes()[:DifferentiatorSize])
+		if parent, ok := parents[tag]; !ok || healthy[parent] < healthy[f] {
+			parents[tag] = f
+		}
+	}
+
+	for differentiator, f := range i.Files {
+		if len(f.Shards) == 0 || healthy[f] > 0 {
+			continue
+		}
+		tag := hex.EncodeToString(f.Shards[0].Tag.Bytes()[:DifferentiatorSize])
+		if parent, ok := parents[tag]; ok {
+			parent.Shards = append(parent.Shards, f.Shards...)
+			delete(i.Files, differentiator)
+		}
+	}
+}
+
+// heal realigns damaged shards that lost or gained a single byte
+// in batches that do not have enough healthy shards. Wide shards
+// are more likely to match a checksum by accident, so batches that
+// can be restored without healing are left alone. The [File] is
+// validated again, if any shards were healed. Shards that cannot
+// be realigned remain erasures, and so do the shards protected
+// by the inner code, whose codewords are shifted by the damage.
+func (f *File) heal(ctx context.Context, sources *sourcePool) (healed int, err error) {
+	if f.ShardSize == 0 {
+		return 0, nil
+	}
+	available := make(map[uint16]int)
+	for _, shard := range f.Shards {
+		if shard.Error == "" {
+			available[shard.Tag.ShardBatch]++
+		}
+	}
+	var identity Tag
+	for _, shard := range f.Shards {
+		if shard.Error == "" {
+			identity = shard.Tag
+			break
+		}
+	}
+	expected := f.ShardSize + TagBytesForCRC + int64(identity.encodedSize())
+	for _, shard := range f.Shards {
+		if shard.Error == "" || shard.Realigned != nil || shard.Unreadable != nil || shard.InnerCode {
+			continue
+		}
+		if available[shard.Tag.ShardBatch] >= int(f.Quorum) {
+			continue
+		}
+		if shard.Size != expected-1 && shard.Size != expected+1 {
+			continue
+		}
+		r, err := sources.Open(shard.Source)
+		if err != nil {
+			continue // cannot heal unreadable shard
+		}
+		b, err := shard.loadChunk(ctx, r, nil)
+		if err != nil || len(b) < TagBytesForCRC+identity.encodedSize() {
+			continue // cannot heal unreadable shard
+		}
+		b = b[TagBytesForCRC:]
+		fix, ok := realign(b, shard.CastagnoliSum, int(expected-TagBytesForCRC))
+		if !ok {
+			continue
+		}
+		// the correction may fall inside the tag
+		tag := NewTagFromBytes(fix.apply(b))
+		if tag.SourceCRC != identity.SourceCRC || tag.SourceSize != identity.SourceSize || tag.ShardQuorum != f.Quorum {
+			continue
+		}
+		shard.Tag = tag
+		shard.Realigned = fix
+		shard.Size = expected
+		shard.Error = ""
+		available[shard.Tag.ShardBatch]++
+		healed++
+	}
+
+	if healed > 0 {
+		f.Error = ""
+		f.validate()
+	}
+	return healed, ctx.Err()
+}
@@ -0,0 +1,120 @@
+package gopar3
+
+import (
+	"bytes"
+	"context"
+	"hash/crc32"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRealign(t *testing.T) {
+	original := make([]byte, 300)
+	for i := range original {
+		original[i] = byte(rand.Intn(256))
+	}
+	sum := crc32.Checksum(original, castagnoliTable)
+
+	for _, at := range [...]int{0, 1, 2, 150, 298, 299} {
+		lengthened := slices.Insert(slices.Clone(original), at, 'x')
+		fix, ok := realign(lengthened, sum, len(original))
+		if !ok {
+			t.Fatalf("failed to realign a byte inserted at %d", at)
+		}
+		if healed := fix.apply(lengthened); !bytes.Equal(healed, original) {
+			t.Fatalf("realignment %+v does not restore the original", fix)
+		}
+
+		shortened := slices.Delete(slices.Clone(original), at, at+1)
+		fix, ok = realign(shortened, sum, len(original))
+		if !ok {
+			t.Fatalf("failed to realign a byte deleted at %d", at)
+		}
+		if healed := fix.apply(shortened); !bytes.Equal(healed, original) {
+			t.Fatalf("realignment %+v does not restore the original", fix)
+		}
+	}
+
+	if _, ok := realign(original[:100], sum, len(original)); ok {
+		t.Fatal("realigned a shard that lost too many bytes")
+	}
+}
+
+func TestRestoreHealsShardLength(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	destination := t.TempDir()
+	if err := Inflate(ctx, destination, "README.md", 5, 1, 64); err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := filepath.Glob(filepath.Join(destination, "*.gopar3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := NewIndex(ctx, outputs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(outputs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// parity covers one erasure, so the first batch
+	// cannot be restored without healing two shards
+	var shards []*Shard
+	for _, f := range index.Files {
+		shards = f.Shards[:2]
+	}
+	damage := func(shard *Shard) int {
+		for at := shard.LastByte - 10; at > shard.FirstByte; at-- {
+			if !bytes.ContainsAny(data[at-1:at+2], `:\`) {
+				return int(at)
+			}
+		}
+		t.Fatal("could not find a byte to damage")
+		return 0
+	}
+	deleteAt, insertAt := damage(shards[0]), damage(shards[1])
+	damaged := slices.Clone(data[:deleteAt])
+	damaged = append(damaged, data[deleteAt+1:insertAt]...)
+	damaged = append(damaged, '!')
+	damaged = append(damaged, data[insertAt:]...)
+	if err = os.WriteFile(outputs[0], damaged, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err = NewIndex(ctx, outputs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Files) != 1 {
+		t.Fatalf("index contains %d files instead of one", len(index.Files))
+	}
+	for _, f := range index.Files {
+		b := &bytes.Buffer{}
+		if err = Restore(ctx, b, f); err != nil {
+			t.Fatal(err)
+		}
+		expected, err := os.ReadFile("README.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b.Bytes(), expected) {
+			t.Fatal("restored file does not match the original")
+		}
+		healed := 0
+		for _, shard := range f.Shards {
+			if shard.Realigned != nil {
+				healed++
+			}
+		}
+		if healed != 1 { // one is enough to reach the quorum
+			t.Fatalf("healed %d shards instead of 1", healed)
+		}
+	}
+}
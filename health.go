@@ -0,0 +1,200 @@
+package gopar3
+
+import (
+	"cmp"
+	"path/filepath"
+	"slices"
+)
+
+// SourceHealth sums up the damage found in one source.
+type SourceHealth struct {
+	Source string
+	// Shards is the number of shards found in the source.
+	Shards int
+	// Damaged counts the shards that failed their checksums, were
+	// realigned, or overlap unreadable bytes. Duplicate and divergent
+	// copies are not damage of the source.
+	Damaged int
+	// Corrected counts the bytes repaired by the inner code.
+	// Growing numbers warn of decay before shards are lost.
+	Corrected int `json:",omitempty"`
+	// Skipped is the number of bytes that could not be read.
+	Skipped int64 `json:",omitempty"`
+	// DamagedRanges are the merged spans of damaged shards
+	// and skipped regions in the order of offsets.
+	DamagedRanges []ByteRange `json:",omitempty"`
+	// FirstDamage and LastDamage are the offsets of the first
+	// and the last damaged bytes. Both are zero without damage.
+	FirstDamage int64
+	LastDamage  int64
+}
+
+// Score is the share of healthy shards among the shards found
+// in the source. A source without shards scores zero, if any
+// of its bytes could not be read, and one otherwise.
+func (h SourceHealth) Score() float64 {
+	if h.Shards == 0 {
+		if h.Skipped > 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(h.Shards-h.Damaged) / float64(h.Shards)
+}
+
+// IsDamaged returns true for shards that were damaged in their
+// source. Duplicate, divergent, and excluded copies are not.
+func (s *Shard) IsDamaged() bool {
+	switch s.Error {
+	case "":
+		return s.Realigned != nil
+	case errDuplicateShard, errDivergentShard, errExcludedSource:
+		return false
+	default:
+		return true
+	}
+}
+
+// Health reports the damage found in each source in the order
+// of [Index.Sources]. Sources that are not listed follow
+// in lexical order.
+func (i Index) Health() []SourceHealth {
+	sources := make(map[string]*SourceHealth)
+	get := func(source string) *SourceHealth {
+		source = filepath.Clean(source)
+		h, ok := sources[source]
+		if !ok {
+			h = &SourceHealth{Source: source}
+			sources[source] = h
+		}
+		return h
+	}
+	for _, source := range i.Sources {
+		get(source)
+	}
+	for _, f := range i.Files {
+		for _, shard := range f.Shards {
+			h := get(shard.Source)
+			h.Shards++
+			h.Corrected += shard.Corrected
+			if shard.IsDamaged() {
+				h.Damaged++
+				h.DamagedRanges = append(h.DamagedRanges, ByteRange{
+					Offset: shard.FirstByte,
+					Size:   shard.LastByte - shard.FirstByte,
+				})
+			}
+		}
+	}
+	for _, skipped := range i.Skipped {
+		h := get(skipped.Source)
+		h.Skipped += skipped.Size
+		h.DamagedRanges = append(h.DamagedRanges, skipped.ByteRange)
+	}
+
+	ranks := i.sourceRanks()
+	health := make([]SourceHealth, 0, len(sources))
+	for _, h := range sources {
+		h.DamagedRanges = mergeByteRanges(h.DamagedRanges)
+		if n := len(h.DamagedRanges); n > 0 {
+			h.FirstDamage = h.DamagedRanges[0].Offset
+			h.LastDamage = h.DamagedRanges[n-1].Offset + h.DamagedRanges[n-1].Size - 1
+		}
+		health = append(health, *h)
+	}
+	rank := func(source string) int {
+		if rank, ok := ranks[source]; ok {
+			return rank
+		}
+		return len(i.Sources)
+	}
+	slices.SortFunc(health, func(a, b SourceHealth) int {
+		return cmp.Or(
+			cmp.Compare(rank(a.Source), rank(b.Source)),
+			cmp.Compare(a.Source, b.Source),
+		)
+	})
+	return health
+}
+
+// mergeByteRanges sorts the ranges and joins
+// the ones that overlap or touch.
+func mergeByteRanges(ranges []ByteRange) (merged []ByteRange) {
+	slices.SortFunc(ranges, func(a, b ByteRange) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && merged[n-1].Offset+merged[n-1].Size >= r.Offset {
+			merged[n-1].Size = max(merged[n-1].Size, r.Offset+r.Size-merged[n-1].Offset)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// errExcludedSource marks the shards of sources
+// removed by [Index.ExcludeSources].
+const errExcludedSource = "source was excluded"
+
+// ExcludeSources turns all shards of the sources into erasures, so
+// that files are restored from other sources. Returns the number
+// of shards that were excluded.
+func (i *Index) ExcludeSources(sources ...string) (excluded int) {
+	sources = cleanPaths(sources)
+	for _, f := range i.Files {
+		for _, shard := range f.Shards {
+			if shard.IsDamaged() || shard.Error == errExcludedSource || !slices.Contains(sources, filepath.Clean(shard.Source)) {
+				continue // damaged shards keep their errors
+			}
+			shard.Error = errExcludedSource
+			excluded++
+		}
+	}
+	if excluded > 0 {
+		i.reselectCopies()
+	}
+	return excluded
+}
+
+// DemoteSources moves the sources to the end of [Index.Sources],
+// so that their copies of shards are used only when other sources
+// lack healthy copies.
+func (i *Index) DemoteSources(sources ...string) {
+	sources = cleanPaths(sources)
+	preferred := make([]string, 0, len(i.Sources)+len(sources))
+	for _, source := range i.Sources {
+		if !slices.Contains(sources, filepath.Clean(source)) {
+			preferred = append(preferred, source)
+		}
+	}
+	i.Sources = append(preferred, sources...)
+	i.reselectCopies()
+}
+
+// reselectCopies selects the best copies of shards again
+// and validates the files that have shards.
+func (i Index) reselectCopies() {
+	ranks := i.sourceRanks()
+	for _, f := range i.Files {
+		if f.ShardSize == 0 {
+			continue // the file was not normalized
+		}
+		for _, shard := range f.Shards {
+			if shard.Error == errDuplicateShard || shard.Error == errDivergentShard {
+				shard.Error = ""
+			}
+		}
+		f.selectCopies(ranks)
+		f.Error = ""
+		f.validate()
+	}
+}
+
+func cleanPaths(paths []string) []string {
+	cleaned := make([]string, len(paths))
+	for i, path := range paths {
+		cleaned[i] = filepath.Clean(path)
+	}
+	return cleaned
+}
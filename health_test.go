@@ -0,0 +1,82 @@
+package gopar3
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSourceHealth(t *testing.T) {
+	for name, holdBack := range map[string]func(*Index, string){
+		"demoted":  func(i *Index, source string) { i.DemoteSources(source) },
+		"excluded": func(i *Index, source string) { i.ExcludeSources(source) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var flipped [2]int64
+			testInflateAndRestoreWithOptions(t, roundTrip{
+				sources: func(t *testing.T, archive string) []string {
+					rotting := copyTestArchive(t, archive, "rotting.gopar3")
+					b, err := os.ReadFile(rotting)
+					if err != nil {
+						t.Fatal(err)
+					}
+					flipped = [...]int64{200, int64(len(b) - 100)} // past the headers
+					for _, i := range flipped {
+						b[i] ^= 0x01
+					}
+					if err = os.WriteFile(rotting, b, 0o644); err != nil {
+						t.Fatal(err)
+					}
+					return []string{rotting, archive}
+				},
+				prepare: func(t *testing.T, index *Index, sources []string) {
+					rotting, healthy := sources[0], sources[1]
+					health := index.Health()
+					if len(health) != 2 || health[0].Source != rotting || health[1].Source != healthy {
+						t.Fatalf("unexpected sources: %+v", health)
+					}
+					if health[1].Damaged != 0 || health[1].Score() != 1 {
+						t.Fatalf("healthy source reported damage: %+v", health[1])
+					}
+					report := health[0]
+					if report.Damaged != 2 || report.Shards != health[1].Shards || len(report.DamagedRanges) != 2 {
+						t.Fatalf("unexpected damage: %+v", report)
+					}
+					if report.FirstDamage > flipped[0] || report.LastDamage < flipped[1] {
+						t.Fatalf("damage %d-%d does not cover the flipped bytes", report.FirstDamage, report.LastDamage)
+					}
+
+					holdBack(index, rotting)
+					for _, file := range index.Files {
+						if file.ShardSize == 0 {
+							continue // tags of corrupt shards differ
+						}
+						for _, shard := range file.Shards {
+							if shard.Source == rotting && shard.Error == "" {
+								t.Fatalf("shard %d of batch %d is still taken from the held back source", shard.Tag.ShardOrder, shard.Tag.ShardBatch)
+							}
+						}
+					}
+					for _, health := range index.Health() {
+						if health.Source == rotting && !reflect.DeepEqual(health, report) {
+							t.Fatalf("holding back the source changed its health: %+v", health)
+						}
+					}
+				},
+			})
+		})
+	}
+}
+
+func TestMergeByteRanges(t *testing.T) {
+	merged := mergeByteRanges([]ByteRange{
+		{Offset: 50, Size: 10},
+		{Offset: 0, Size: 10},
+		{Offset: 10, Size: 5},
+		{Offset: 52, Size: 3},
+	})
+	expected := []ByteRange{{Offset: 0, Size: 15}, {Offset: 50, Size: 10}}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Fatalf("unexpected ranges: %v", merged)
+	}
+}
@@ -2,16 +2,20 @@ package gopar3
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"path/filepath"
 	"runtime"
 	"slices"
 	"sync"
 
+	"github.com/dkotik/gopar3/ecc"
 	"github.com/dkotik/gopar3/telomeres"
 	"golang.org/x/sync/errgroup"
 )
@@ -25,6 +29,27 @@ type Shard struct {
 	LastByte      int64
 	CastagnoliSum uint32
 	Error         string
+	// SubChunks are spans guarded by telomere sync markers,
+	// if the shard was written with them. Offsets are relative
+	// to the first byte of the shard checksum.
+	SubChunks []telomeres.SubChunk `json:",omitempty"`
+	// Realigned is set when a shard, damaged by an insertion
+	// or a deletion, was healed to its original size.
+	Realigned *Realignment `json:",omitempty"`
+	// Telomeres are the marks framing the shard, if they differ
+	// from [telomeres.DefaultMarks].
+	Telomeres *telomeres.Marks  `json:",omitempty"`
+	Framing   telomeres.Framing `json:",omitempty"`
+	// InnerCode is set for shards written with [WithInnerCode].
+	// Size then counts the decoded bytes.
+	InnerCode bool `json:",omitempty"`
+	// Corrected is the number of bytes repaired by the inner code.
+	Corrected int `json:",omitempty"`
+	// Trailer is decoded from shards tagged by [Tag.IsTrailer].
+	Trailer *Trailer `json:",omitempty"`
+	// Unreadable is the first known bad range that the shard
+	// overlaps. See [Index.MarkUnreadable].
+	Unreadable *ByteRange `json:",omitempty"`
 	Tag
 }
 
@@ -42,7 +67,6 @@ func (s *Shard) Differentiator() string {
 
 // Load reads associated data from disk into bytes
 func (s *Shard) Load(ctx context.Context) (_ []byte, err error) {
-	// TODO: this function should be run in a map[source]*Reader
 	f, err := os.Open(s.Source)
 	if err != nil {
 		return nil, err
@@ -50,42 +74,147 @@ func (s *Shard) Load(ctx context.Context) (_ []byte, err error) {
 	defer func() {
 		err = errors.Join(err, f.Close())
 	}()
-	if _, err = f.Seek(s.FirstByte, io.SeekStart); err != nil {
+	return s.load(ctx, f, nil)
+}
+
+// load reads associated data from an open source. The data
+// is decoded into the buffer, if it has enough capacity.
+func (s *Shard) load(ctx context.Context, r io.ReaderAt, buffer []byte) ([]byte, error) {
+	b, err := s.loadChunk(ctx, r, buffer)
+	if err != nil {
 		return nil, err
 	}
-	r := telomeres.NewDecoder(f)
-	// if err = r.SeekChunk(ctx); err != nil {
-	// 	return nil, err
-	// }
-	b := &bytes.Buffer{}
-	if _, err = r.StreamChunk(ctx, b); err != nil {
+	if s.Realigned != nil {
+		b = append(b[:TagBytesForCRC], s.Realigned.apply(b[TagBytesForCRC:])...)
+	}
+	if len(b) < TagBytesForCRC+s.Tag.encodedSize() {
+		return nil, ErrShardTooSmall
+	}
+	return b[TagBytesForCRC+s.Tag.encodedSize():], nil
+}
+
+// payloadSize returns the number of data bytes that
+// the shard carries after the checksum and the tag.
+func (s *Shard) payloadSize() int64 {
+	return s.Size - TagBytesForCRC - int64(s.Tag.encodedSize())
+}
+
+// loadChunk reads the decoded shard including the checksum and tag
+// from the source section between [Shard.FirstByte] and [Shard.LastByte].
+// Errors are corrected by the inner code, if the shard carries it,
+// and counted in [Shard.Corrected]. Shards with uncorrectable
+// codewords return [ecc.ErrUncorrectable].
+func (s *Shard) loadChunk(ctx context.Context, r io.ReaderAt, buffer []byte) (_ []byte, err error) {
+	options := []telomeres.DecoderOption{telomeres.WithDecoderFraming(s.Framing)}
+	if s.Telomeres != nil {
+		options = append(options, telomeres.WithDecoderMarks(*s.Telomeres))
+	}
+	size := max(s.LastByte-s.FirstByte, 0)
+	d := telomeres.NewDecoder(io.NewSectionReader(r, s.FirstByte, size), options...)
+	if buffer == nil {
+		buffer = make([]byte, 0, size)
+	}
+	b := bytes.NewBuffer(buffer[:0])
+	if _, err = d.StreamChunk(ctx, b); err != nil {
 		return nil, err
 	}
-	return b.Bytes()[TagBytesForCRC+TagSize:], nil
+	if s.InnerCode {
+		decoded, corrected, err := ecc.DecodeAll(b.Bytes())
+		s.Corrected = corrected
+		if err != nil {
+			return nil, fmt.Errorf("shard at byte %d of %s: %w", s.FirstByte, s.Source, err)
+		}
+		return decoded, nil
+	}
+	return b.Bytes(), nil
 }
 
 type File struct {
-	Shards        []*Shard
-	Quorum        uint8
-	Size          uint64
+	Shards []*Shard
+	Quorum uint16
+	// Parity is the number of parity shards in each batch.
+	// It is zero for archives that do not record it.
+	Parity uint16
+	// Codec computes the parity shards, see [WithCodec]. It is
+	// taken from extended tags, or else from the [Header] of the
+	// archive, if one is intact.
+	Codec Codec `json:",omitempty"`
+	Size  uint64
+	// ShardSize is the number of data bytes carried by healthy
+	// shards without the checksum and tag. It is taken from the
+	// [Header] of the archive, if one is intact and some shards
+	// have that size, or is the most common size among the shards.
+	ShardSize     int64
 	Padding       uint64
 	Batches       uint16
 	CastagnoliSum uint32
-	Error         string
+	// Trailer carries the checksum of a file inflated in a single pass.
+	Trailer *Trailer `json:",omitempty"`
+	// Unverified is true when the file was inflated in a single pass,
+	// but its trailer was lost. Restored data cannot be verified.
+	Unverified bool `json:",omitempty"`
+	// Divergent counts the shards with checksum-clean copies that
+	// differ from each other, which means that different archives
+	// share the differentiator. All such copies are erasures.
+	Divergent int `json:",omitempty"`
+	// Mismatches are the parameters that some shards or the trailer
+	// record differently from the rest of the file. Mismatched shards
+	// are erasures.
+	Mismatches []MismatchError `json:",omitempty"`
+	Error      string
+	// err is the structured cause of Error. See [File.Err].
+	err error
 }
 
 // Index is a map of known shards arranged by [Tag.BlockDifferentiator]
 // gathered from a list of files that could contain recovery data
 // for any number of files. Index can be saved to complete
 // recovery operations in more than one execution.
-type Index map[string]*File
+type Index struct {
+	Files map[string]*File
+	// Sources are the scanned files in order of preference. When
+	// a shard has several healthy copies, the one from the earliest
+	// source is used, so fast or local sources should come first.
+	Sources []string `json:",omitempty"`
+	// Skipped are the regions of sources that could not be read.
+	// Shards overlapping them are erasures.
+	Skipped []SkippedRegion `json:",omitempty"`
+	// Headers describe the archives found in the sources.
+	// See [Header].
+	Headers []SourceHeader `json:",omitempty"`
+}
+
+// UnmarshalJSON also reads indexes saved before [Index.Skipped]
+// was added, which were a plain map of files by differentiator.
+func (i *Index) UnmarshalJSON(b []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if _, ok := fields["Files"]; !ok {
+		// differentiators never take the name of a field
+		files := make(map[string]*File, len(fields))
+		if err := json.Unmarshal(b, &files); err != nil {
+			return err
+		}
+		*i = Index{Files: files}
+		return nil
+	}
+	type index Index // without this method
+	return json.Unmarshal(b, (*index)(i))
+}
 
 func (i Index) Normalize() (err error) {
-	if len(i) == 0 {
+	if len(i.Files) == 0 {
 		return errors.New("no data shards were detected in input files")
 	}
-	var shardSize int64
-	for _, f := range i {
+	i.adoptTrailers()
+	i.adoptStrayShards()
+	ranks := i.sourceRanks()
+	for _, f := range i.Files {
+		f.ShardSize = 0
+		sizes := make(map[int64]int)
+		singlePass, extended := false, false
 		for _, shard := range f.Shards {
 			if shard.Error != "" {
 				continue // do not consider data from corrupt shards
@@ -94,87 +223,296 @@ func (i Index) Normalize() (err error) {
 			// because shards are already grouped by differentiator
 			// as the Index key
 			f.CastagnoliSum = shard.Tag.SourceCRC
-			f.Size = shard.Tag.SourceSize
+			f.Size = shard.Tag.Size()
 			f.Quorum = shard.Tag.ShardQuorum
-			shardSize = shard.Size
-			break // found one recoverable
+			if shards := shard.Tag.Shards(); shards > int(f.Quorum) {
+				f.Parity = uint16(shards - int(f.Quorum))
+			}
+			f.Codec = shard.Tag.Codec
+			extended = shard.Tag.IsExtended()
+			singlePass = shard.Tag.IsSinglePass()
+			sizes[shard.payloadSize()]++
 		}
-		if f.CastagnoliSum == 0 {
+		if len(sizes) == 0 {
 			f.Error = "there are no recoverable shards"
 			continue
 		}
-
-		slices.SortFunc(f.Shards, func(a, b *Shard) int {
-			// return a negative number when a < b,
-			// a positive number when a > b,
-			// zero when a == b
-			if a.Tag.ShardBatch < b.Tag.ShardBatch {
-				return -1
-			} else if a.Tag.ShardBatch > b.Tag.ShardBatch {
-				return 1
-			}
-			if a.Tag.ShardOrder < b.Tag.ShardOrder {
-				return -1
-			} else if a.Tag.ShardOrder > b.Tag.ShardOrder {
-				return 1
+		// the header may belong to another archive in the
+		// same source, so only values that agree with the
+		// shards are taken from it
+		header, hasHeader := i.fileHeader(f)
+		if hasHeader && !extended {
+			f.Codec = header.Codec // standard tags do not record it
+		}
+		f.Unverified = singlePass && f.Trailer == nil
+		if singlePass && f.Trailer != nil {
+			if f.Trailer.SourceSize != f.Size {
+				mismatch := MismatchError{
+					Parameter: MismatchTrailerSourceSize,
+					Expected:  f.Size,
+					Found:     f.Trailer.SourceSize,
+				}
+				f.addMismatch(mismatch)
+				f.setError(&mismatch)
+				continue
 			}
-			return 0
+			f.CastagnoliSum = f.Trailer.SourceCRC
+		}
+		// computed for each file, because archives
+		// of different shard sizes can be mixed
+		if f.ShardSize = mostCommonShardSize(sizes); hasHeader && sizes[int64(header.ShardSize)] > 0 {
+			f.ShardSize = int64(header.ShardSize)
+		}
+		if f.ShardSize == 0 {
+			f.Error = "there are no recoverable shards"
+			continue
+		}
+		f.rejectOutliers()
+
+		f.selectCopies(ranks)
+		f.validate()
+	}
+	return nil
+}
+
+// sourceRanks returns the positions of [Index.Sources].
+func (i Index) sourceRanks() map[string]int {
+	ranks := make(map[string]int, len(i.Sources))
+	for rank, source := range i.Sources {
+		if _, ok := ranks[filepath.Clean(source)]; !ok {
+			ranks[filepath.Clean(source)] = rank
+		}
+	}
+	return ranks
+}
+
+// selectCopies sorts the shards by batch and order, placing
+// the best copy of each shard first: healthy copies before
+// damaged ones, original copies before realigned ones, and copies
+// from sources with lower ranks before others. Sources without
+// a rank come last in lexical order. Checksum-clean copies that
+// differ from the best copy turn all copies into erasures.
+func (f *File) selectCopies(ranks map[string]int) {
+	rank := func(s *Shard) int {
+		if rank, ok := ranks[filepath.Clean(s.Source)]; ok {
+			return rank
+		}
+		return math.MaxInt
+	}
+	slices.SortFunc(f.Shards, func(a, b *Shard) int {
+		return cmp.Or(
+			cmp.Compare(a.Tag.ShardBatch, b.Tag.ShardBatch),
+			cmp.Compare(a.Tag.ShardOrder, b.Tag.ShardOrder),
+			compareBool(a.Error != "", b.Error != ""),
+			compareBool(a.Realigned != nil, b.Realigned != nil),
+			cmp.Compare(rank(a), rank(b)),
+			cmp.Compare(a.Source, b.Source),
+			cmp.Compare(a.FirstByte, b.FirstByte),
+		)
+	})
+
+	f.Divergent = 0
+	for start := 0; start < len(f.Shards); {
+		end := start + 1
+		for end < len(f.Shards) &&
+			f.Shards[end].Tag.ShardBatch == f.Shards[start].Tag.ShardBatch &&
+			f.Shards[end].Tag.ShardOrder == f.Shards[start].Tag.ShardOrder {
+			end++
+		}
+		copies := f.Shards[start:end]
+		start = end
+		if copies[0].Error != "" {
+			continue
+		}
+		divergent := slices.ContainsFunc(copies[1:], func(s *Shard) bool {
+			return s.Error == "" && s.CastagnoliSum != copies[0].CastagnoliSum
 		})
+		for i, shard := range copies {
+			switch {
+			case shard.Error != "":
+			case divergent:
+				shard.Error = errDivergentShard
+				f.Divergent++
+			case i > 0:
+				shard.Error = errDuplicateShard
+			}
+		}
+	}
+}
 
-		f.Batches = uint16(math.Ceil(
-			float64(f.Size)/float64(shardSize*int64(f.Quorum)),
-		)) + 1
-		f.Padding = uint64(f.Batches)*uint64(f.Quorum)*uint64(shardSize) - f.Size
-
-		// validate file
-		batch := make(map[uint8]uint32)
-		currentBatch := uint16(0)
-		quorum := int(f.Quorum)
-		knownSum := uint32(0)
-		ok := false
-		for _, shard := range f.Shards {
-			if shard.Error != "" {
-				continue // do not consider data from corrupt shards
+// compareBool orders false before true.
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// errDuplicateShard marks healthy shards that are already
+// present in the batch.
+const errDuplicateShard = "duplicate shard"
+
+// errDivergentShard marks checksum-clean copies of a shard
+// that differ from each other. See [File.Divergent].
+const errDivergentShard = "divergent shard: checksum-clean copies differ"
+
+// mostCommonShardSize picks the data size carried by most shards.
+// Ties go to the smaller size, so that the choice does not depend
+// on map order. Sizes below one byte cannot be restored.
+func mostCommonShardSize(sizes map[int64]int) (size int64) {
+	most := 0
+	for candidate, count := range sizes {
+		if candidate < 1 {
+			continue
+		}
+		if count > most || count == most && candidate < size {
+			size, most = candidate, count
+		}
+	}
+	return size
+}
+
+// validate checks that every batch of a sorted [File] has enough
+// recoverable shards and marks the duplicate shards.
+func (f *File) validate() {
+	// the layout follows from the source size in the tags
+	batchSize := uint64(f.ShardSize) * uint64(f.Quorum)
+	if batchSize == 0 {
+		f.Error = "there are no recoverable shards"
+		return
+	}
+	batches := (f.Size + batchSize - 1) / batchSize
+	f.Batches = uint16(batches) // wraps like the batch counter
+	f.Padding = batches*batchSize - f.Size
+
+	batch := make(map[uint16]uint32)
+	currentBatch := uint16(0)
+	quorum := int(f.Quorum)
+	knownSum := uint32(0)
+	ok := false
+	for _, shard := range f.Shards {
+		if shard.Error != "" {
+			continue // do not consider data from corrupt shards
+		}
+		if shard.Tag.ShardBatch != currentBatch {
+			if len(batch) < quorum {
+				f.Error = fmt.Sprintf("batch %d has %d recoverable shards instead of %d required", currentBatch, len(batch), quorum)
+				return
 			}
+			currentBatch++
 			if shard.Tag.ShardBatch != currentBatch {
-				if len(batch) < quorum {
-					f.Error = fmt.Sprintf("batch %d has %d recoverable shards instead of %d required", currentBatch, len(batch), quorum)
-					break
-				}
-				currentBatch++
-				if shard.Tag.ShardBatch != currentBatch {
-					f.Error = fmt.Sprintf("there are no recoverable shards for batch %d", currentBatch)
-					break
-				}
-				batch = make(map[uint8]uint32) // reset
+				f.Error = fmt.Sprintf("there are no recoverable shards for batch %d", currentBatch)
+				return
 			}
-			if knownSum, ok = batch[shard.Tag.ShardOrder]; ok {
-				if shard.CastagnoliSum == knownSum {
-					shard.Error = "duplicate shard"
-				} else {
-					shard.Error = "duplicate shard with corrupt CRC"
-				}
+			batch = make(map[uint16]uint32) // reset
+		}
+		if knownSum, ok = batch[shard.Tag.ShardOrder]; ok {
+			if shard.CastagnoliSum == knownSum {
+				shard.Error = errDuplicateShard
 			} else {
-				batch[shard.Tag.ShardOrder] = shard.CastagnoliSum
+				shard.Error = errDivergentShard
+				f.Divergent++
 			}
+		} else {
+			batch[shard.Tag.ShardOrder] = shard.CastagnoliSum
 		}
-		if currentBatch+1 < f.Batches {
-			f.Error = fmt.Sprintf("there are only %d recoverable batches out of %d required for restoration", currentBatch+1, f.Batches)
+	}
+	if currentBatch+1 < f.Batches {
+		f.Error = fmt.Sprintf("there are only %d recoverable batches out of %d required for restoration", currentBatch+1, f.Batches)
+	}
+}
+
+// framingProbeShards is the number of leading shards decoded
+// with each framing mode by [detectFraming].
+const framingProbeShards = 8
+
+// detectFraming decodes the first shards of a file with each
+// [telomeres.Framing], with and without the inner code, and picks
+// the combination that produced more shards with matching checksums.
+// The file is rewound.
+func detectFraming(
+	ctx context.Context,
+	source string,
+	f io.ReadSeeker,
+	marks telomeres.Marks,
+	carve bool,
+) (framing telomeres.Framing, innerCode bool, err error) {
+	mostValid := -1
+	for _, candidate := range [...]telomeres.Framing{
+		telomeres.FramingEscape,
+		telomeres.FramingStuffing,
+	} {
+		for _, inner := range [...]bool{false, true} {
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				return framing, innerCode, err
+			}
+			r := NewReader(source, f,
+				telomeres.WithDecoderMarks(marks),
+				telomeres.WithDecoderFraming(candidate),
+			)
+			r.InnerCode = inner
+			valid := 0
+			if carve {
+				valid = probeCarvedShards(ctx, r)
+			} else {
+				valid = probeShards(ctx, r)
+			}
+			if valid > mostValid {
+				framing, innerCode, mostValid = candidate, inner, valid
+			}
 		}
-		// panic(f.Batches)
 	}
-	return nil
+	_, err = f.Seek(0, io.SeekStart)
+	return framing, innerCode, err
 }
 
+// probeShards counts valid shards among the first
+// [framingProbeShards] chunks.
+func probeShards(ctx context.Context, r *Reader) (valid int) {
+	for range framingProbeShards {
+		shard, err := r.NextShard(ctx, io.Discard)
+		if err != nil {
+			break
+		}
+		if shard.Error == "" {
+			valid++
+		}
+	}
+	return valid
+}
+
+// MarkDetectionSampleSize is the number of leading bytes of each
+// file sampled by [telomeres.DetectMarks] in [NewIndex].
+const MarkDetectionSampleSize = 1 << 20
+
 // NewIndex scans files for shards and recovers as much information
 // about them as possible to assess the presence and possibility
 // of data recovery in those shards.
 func NewIndex(ctx context.Context, files ...string) (index Index, err error) {
+	return ScanIndex(ctx, files)
+}
+
+// ScanIndex scans files for shards like [NewIndex] with options.
+// Carving skips the data that does not decode into plausible shards
+// with matching checksums. See [NewCarvedIndex].
+func ScanIndex(ctx context.Context, files []string, withOptions ...IndexOption) (index Index, err error) {
+	options, err := newIndexOptions(withOptions...)
+	if err != nil {
+		return Index{}, err
+	}
+	reporter := newProgressReporter(options.progress, "index")
 	for _, source := range files {
 		info, err := os.Stat(source)
 		if err != nil {
-			return nil, err
+			return Index{}, err
 		}
+		reporter.Update(func(p *Progress) {
+			p.BytesTotal += info.Size()
+		})
 		if info.IsDir() {
 			// TODO: queue files within the folders
 			// dir, err := os.ReadDir(source)
@@ -187,52 +525,168 @@ func NewIndex(ctx context.Context, files ...string) (index Index, err error) {
 			//   }
 			//   files = append(files, file)
 			// }
-			return nil, fmt.Errorf("cannot read a directory: %s", source)
+			return Index{}, fmt.Errorf("cannot read a directory: %s", source)
 		}
 	}
 
 	wg, ctx := errgroup.WithContext(ctx)
 	wg.SetLimit(runtime.NumCPU())
-	index = make(Index)
+	index = Index{Files: make(map[string]*File), Sources: files}
 	mu := &sync.Mutex{}
 
 	for _, file := range files {
 		wg.Go(func() (err error) {
-			f, err := os.Open(file)
+			info, err := os.Stat(file)
 			if err != nil {
 				return err
 			}
+			size := info.Size()
+			f, err := os.Open(file)
+			if err != nil { // listed instead of failing other sources
+				mu.Lock()
+				index.Skipped = append(index.Skipped, SkippedRegion{
+					Source:    file,
+					ByteRange: ByteRange{Size: size},
+					Error:     err.Error(),
+				})
+				mu.Unlock()
+				return nil
+			}
+			source := &skippingReader{r: f, source: file, size: size}
+			for _, r := range options.unreadable[filepath.Clean(file)] {
+				if r.Size > 0 {
+					source.skip(r, errUnreadable)
+				}
+			}
+			var headers []SourceHeader
 			defer func() {
 				if err == io.EOF {
 					err = nil
 				}
+				mu.Lock()
+				index.Skipped = append(index.Skipped, source.skipped...)
+				index.Headers = append(index.Headers, headers...)
+				mu.Unlock()
 				err = errors.Join(err, f.Close())
 			}()
 
-			r := NewReader(file, f)
-			for {
-				// b := &bytes.Buffer{}
-				// shard, err := r.NextShard(ctx, b)
-				// log.Fatalf("%s", b.String())
-				shard, err := r.NextShard(ctx, io.Discard)
-				if err != nil {
-					break
-				}
+			return scanSource(ctx, source, options, reporter, &headers, func(shard *Shard) {
 				differentiator := shard.Differentiator()
 				mu.Lock()
-				file, ok := index[differentiator]
+				defer mu.Unlock()
+				file, ok := index.Files[differentiator]
 				if !ok {
 					file = &File{}
-					index[differentiator] = file
+					index.Files[differentiator] = file
 				}
 				file.Shards = append(file.Shards, shard)
-				mu.Unlock()
-			}
-			return err
+			})
 		})
 	}
 
-	return index, errors.Join(wg.Wait(), index.Normalize())
+	err = wg.Wait()
+	slices.SortFunc(index.Skipped, func(a, b SkippedRegion) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Offset, b.Offset))
+	})
+	slices.SortFunc(index.Headers, func(a, b SourceHeader) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.FirstByte, b.FirstByte))
+	})
+	if err = errors.Join(err, index.Normalize()); err != nil {
+		return index, err
+	}
+	reporter.Finish()
+	return index, nil
+}
+
+// sourceFraming returns the marks, framing, and inner code recorded
+// in the [Header] at the start of the source. Sources without an
+// intact header copy, or with other marks given, are probed: marks
+// are detected by frequency analysis, which can guess wrong for sparse
+// data, unless given, and the framing by [detectFraming].
+func sourceFraming(ctx context.Context, source *skippingReader, options *indexOptions) (
+	marks telomeres.Marks,
+	framing telomeres.Framing,
+	innerCode bool,
+	err error,
+) {
+	header, found, err := readHeader(ctx, source)
+	if err != nil {
+		return marks, framing, innerCode, err
+	}
+	if found && (options.marks == nil || *options.marks == header.Marks) {
+		return header.Marks, header.Framing, header.InnerCode, nil
+	}
+	if options.marks != nil {
+		marks = *options.marks
+	} else if marks, err = telomeres.DetectMarks(io.LimitReader(source, MarkDetectionSampleSize)); err != nil && err != telomeres.ErrNoTelomeres {
+		return marks, framing, innerCode, err
+	}
+	framing, innerCode, err = detectFraming(ctx, source.source, source, marks, options.carve)
+	return marks, framing, innerCode, err
+}
+
+// scanSource passes every shard found in the source to the callback
+// and collects the headers. Read errors do not stop the scan: the
+// failed regions are skipped and recorded, and the shards overlapping
+// them become erasures.
+func scanSource(
+	ctx context.Context,
+	source *skippingReader,
+	options *indexOptions,
+	reporter *progressReporter,
+	headers *[]SourceHeader,
+	found func(*Shard),
+) (err error) {
+	carve := options.carve
+	marks, framing, innerCode, err := sourceFraming(ctx, source, options)
+	if err != nil {
+		return err
+	}
+	source.fill = marks.Mark // skipped regions read as telomeres
+	r := NewReader(source.source, source,
+		telomeres.WithDecoderMarks(marks),
+		telomeres.WithDecoderFraming(framing),
+	)
+	r.InnerCode = innerCode
+	var scanned, skipped int64
+	defer func() {
+		*headers = r.Headers
+		reporter.Update(func(p *Progress) {
+			p.BytesRead += source.size - scanned // remainder after the last shard
+		})
+	}()
+	for {
+		// b := &bytes.Buffer{}
+		// shard, err := r.NextShard(ctx, b)
+		// log.Fatalf("%s", b.String())
+		shard, err := r.NextShard(ctx, io.Discard)
+		if err != nil || carve && !isCarvedShard(shard, nil) {
+			if err == io.EOF || ctx.Err() != nil || shard.LastByte <= skipped {
+				return ctx.Err()
+			}
+			skipped = shard.LastByte
+			continue // undecodable chunk or noise
+		}
+		if !source.markSkipped(shard) {
+			continue
+		}
+		reporter.Update(func(p *Progress) {
+			p.BytesRead += shard.LastByte - scanned
+			p.ShardsScanned++
+			if shard.Error != "" {
+				p.Errors++
+			}
+		})
+		scanned = shard.LastByte
+		if shard.Tag.IsTrailer() && shard.Error == "" {
+			if b, err := shard.load(ctx, source.r, nil); err == nil {
+				if trailer, err := NewTrailerFromBytes(b); err == nil {
+					shard.Trailer = &trailer
+				}
+			}
+		}
+		found(shard)
+	}
 }
 
 func (i *Index) AddFile(
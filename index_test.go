@@ -1,11 +1,15 @@
 package gopar3
 
 import (
+	"context"
+	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestShardLoading(t *testing.T) {
@@ -31,3 +35,76 @@ func TestShardLoading(t *testing.T) {
 		}
 	}
 }
+
+func TestSelectCopies(t *testing.T) {
+	shard := func(source string, order uint16, sum uint32, err string) *Shard {
+		return &Shard{
+			Source:        source,
+			CastagnoliSum: sum,
+			Error:         err,
+			Tag:           Tag{ShardOrder: order},
+		}
+	}
+	corrupt := shard("local", 0, 9, "corrupted shard")
+	best := shard("local", 0, 1, "")
+	remote := shard("remote", 0, 1, "")
+	unranked := shard("unranked", 0, 1, "")
+	divergent := []*Shard{shard("local", 1, 1, ""), shard("remote", 1, 2, "")}
+	f := &File{Shards: append([]*Shard{unranked, remote, corrupt, best}, divergent...)}
+
+	f.selectCopies(map[string]int{"local": 0, "remote": 1})
+	if f.Shards[0] != best || best.Error != "" {
+		t.Fatalf("the best copy was not selected: %+v", f.Shards[0])
+	}
+	for _, duplicate := range []*Shard{remote, unranked} {
+		if duplicate.Error != errDuplicateShard {
+			t.Errorf("copy from %q was not marked as duplicate: %q", duplicate.Source, duplicate.Error)
+		}
+	}
+	if f.Shards[1] != remote || f.Shards[2] != unranked || f.Shards[3] != corrupt {
+		t.Error("copies are not ordered by preference")
+	}
+	if f.Divergent != len(divergent) {
+		t.Fatalf("found %d divergent shards instead of %d", f.Divergent, len(divergent))
+	}
+	for _, shard := range divergent {
+		if shard.Error != errDivergentShard {
+			t.Errorf("divergent copy from %q was not erased: %q", shard.Source, shard.Error)
+		}
+	}
+}
+
+func TestIndexJSON(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	archive := filepath.Join(t.TempDir(), "archive.gopar3")
+	if err := Inflate(ctx, archive, "README.md", 3, 2, 64); err != nil {
+		t.Fatal(err)
+	}
+	index, err := NewIndex(ctx, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Skipped = []SkippedRegion{{Source: archive, ByteRange: ByteRange{Size: 1}, Error: "failed"}}
+
+	current, err := json.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := json.Marshal(index.Files) // saved before the index had other fields
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, b := range map[string][]byte{"current": current, "legacy": legacy} {
+		var decoded Index
+		if err = json.Unmarshal(b, &decoded); err != nil {
+			t.Fatal(name, err)
+		}
+		if !reflect.DeepEqual(decoded.Files, index.Files) {
+			t.Fatalf("%s index files do not match after decoding", name)
+		}
+		if name == "current" && !reflect.DeepEqual(decoded, index) {
+			t.Fatal("current index does not match after decoding")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package gopar3
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+var benchmarkInflateSize = flag.Int64(
+	"inflate.size", 64<<20,
+	"source size in bytes for inflate benchmarks, set to gigabytes for disk throughput",
+)
+
+func TestInflateJobsProduceIdenticalOutput(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	var outputs [][]byte
+	created := time.Now()
+	for _, jobs := range []int{1, 2, 7} {
+		destination := t.TempDir()
+		if err := Inflate(ctx, destination, "README.md", 3, 2, 32, WithInflateJobs(jobs), WithCreationTime(created)); err != nil {
+			t.Fatal(err)
+		}
+		matches, err := filepath.Glob(filepath.Join(destination, "*.gopar3"))
+		if err != nil || len(matches) != 1 {
+			t.Fatalf("expected one output, got %v: %v", matches, err)
+		}
+		b, err := os.ReadFile(matches[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, b)
+	}
+	for i, output := range outputs[1:] {
+		if !bytes.Equal(output, outputs[0]) {
+			t.Fatalf("output of run #%d differs from sequential output", i+1)
+		}
+	}
+}
+
+func BenchmarkInflate(b *testing.B) {
+	source := filepath.Join(b.TempDir(), "source.bin")
+	f, err := os.Create(source)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err = io.CopyN(f, rand.New(rand.NewSource(1)), *benchmarkInflateSize); err != nil {
+		b.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, jobs := range []int{1, max(2, runtime.NumCPU())} {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			b.SetBytes(*benchmarkInflateSize)
+			for i := 0; i < b.N; i++ {
+				destination := b.TempDir()
+				if err = Inflate(ctx, destination, source, 10, 4, 64*1024, WithInflateJobs(jobs)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package gopar3
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	// MismatchShardSize is reported for healthy shards that carry
+	// more or fewer data bytes than [File.ShardSize].
+	MismatchShardSize = "shard size"
+
+	// MismatchTrailerSourceSize is reported for trailers that
+	// record a source size other than the one in the tags.
+	MismatchTrailerSourceSize = "trailer source size"
+)
+
+// MismatchError reports a parameter that some shards or the trailer
+// of a [File] record differently from the rest of the file.
+type MismatchError struct {
+	Parameter string
+	Expected  uint64
+	Found     uint64
+	// Shards is the number of shards with the found value.
+	Shards int `json:",omitempty"`
+}
+
+func (e *MismatchError) Error() string {
+	if e.Shards > 0 {
+		return fmt.Sprintf("%d shards have %s %d instead of %d", e.Shards, e.Parameter, e.Found, e.Expected)
+	}
+	return fmt.Sprintf("%s %d does not match %d", e.Parameter, e.Found, e.Expected)
+}
+
+// Err returns the reason the file cannot be restored. Mismatched
+// parameters are returned as [*MismatchError] until the index
+// is saved. Returns nil for restorable files.
+func (f *File) Err() error {
+	if f.Error == "" {
+		return nil
+	}
+	if f.err != nil && f.err.Error() == f.Error {
+		return f.err
+	}
+	return errors.New(f.Error)
+}
+
+// setError records the reason the file cannot be restored.
+func (f *File) setError(err error) {
+	f.err, f.Error = err, err.Error()
+}
+
+// addMismatch records the mismatch once, so that
+// normalizing the index again does not repeat it.
+func (f *File) addMismatch(m MismatchError) {
+	if !slices.Contains(f.Mismatches, m) {
+		f.Mismatches = append(f.Mismatches, m)
+	}
+}
+
+// rejectOutliers turns healthy shards that carry a different number
+// of data bytes than [File.ShardSize] into erasures. A checksum-clean
+// shard of the wrong size would otherwise confuse the batch layout.
+// Each outlier size is recorded as a [MismatchError].
+func (f *File) rejectOutliers() {
+	outliers := make(map[int64]int)
+	for _, shard := range f.Shards {
+		if shard.Error != "" || shard.Tag.IsTrailer() {
+			continue
+		}
+		if size := shard.payloadSize(); size != f.ShardSize {
+			shard.Error = (&MismatchError{
+				Parameter: MismatchShardSize,
+				Expected:  uint64(f.ShardSize),
+				Found:     uint64(size),
+			}).Error()
+			outliers[size]++
+		}
+	}
+	for _, size := range sortedSizes(outliers) {
+		f.addMismatch(MismatchError{
+			Parameter: MismatchShardSize,
+			Expected:  uint64(f.ShardSize),
+			Found:     uint64(size),
+			Shards:    outliers[size],
+		})
+	}
+}
+
+// sortedSizes returns the sizes in increasing order.
+func sortedSizes(sizes map[int64]int) []int64 {
+	sorted := make([]int64, 0, len(sizes))
+	for size := range sizes {
+		sorted = append(sorted, size)
+	}
+	slices.Sort(sorted)
+	return sorted
+}
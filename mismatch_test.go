@@ -0,0 +1,99 @@
+package gopar3
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMostCommonShardSize(t *testing.T) {
+	testCases := [...]struct {
+		Sizes    map[int64]int
+		Expected int64
+	}{
+		{Sizes: map[int64]int{}, Expected: 0},
+		{Sizes: map[int64]int{0: 9, -4: 3}, Expected: 0},
+		{Sizes: map[int64]int{0: 9, 32: 1}, Expected: 32},
+		{Sizes: map[int64]int{32: 4, 40: 1}, Expected: 32},
+		{Sizes: map[int64]int{48: 2, 32: 2, 40: 1}, Expected: 32},
+	}
+	for _, tc := range testCases {
+		for range 10 { // map order must not matter
+			if size := mostCommonShardSize(tc.Sizes); size != tc.Expected {
+				t.Fatalf("shard size %d was picked from %v instead of %d", size, tc.Sizes, tc.Expected)
+			}
+		}
+	}
+}
+
+func TestNormalizeRejectsShardSizeOutliers(t *testing.T) {
+	shard := func(batch, order uint16, payload int64) *Shard {
+		return &Shard{
+			Source:        "local",
+			CastagnoliSum: uint32(batch)<<16 | uint32(order),
+			Size:          TagBytesForCRC + TagSize + payload,
+			Tag: Tag{
+				SourceSize:  100,
+				ShardQuorum: 2,
+				ShardBatch:  batch,
+				ShardOrder:  order,
+			},
+		}
+	}
+	outliers := []*Shard{shard(1, 1, 40), shard(0, 2, 40)}
+	f := &File{Shards: append([]*Shard{
+		shard(0, 0, 32), shard(0, 1, 32), shard(1, 0, 32), shard(1, 1, 32),
+	}, outliers...)}
+	index := Index{Files: map[string]*File{"mixed": f}}
+	if err := index.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Error != "" {
+		t.Fatal("file cannot be restored:", f.Error)
+	}
+	if f.ShardSize != 32 || f.Batches != 2 || f.Padding != 28 {
+		t.Fatalf("layout of %d bytes does not follow the tags: %d batches of %d byte shards with %d bytes of padding", f.Size, f.Batches, f.ShardSize, f.Padding)
+	}
+	for _, outlier := range outliers {
+		if outlier.Error == "" {
+			t.Errorf("outlier %d/%d was not erased", outlier.Tag.ShardBatch, outlier.Tag.ShardOrder)
+		}
+	}
+	expected := MismatchError{Parameter: MismatchShardSize, Expected: 32, Found: 40, Shards: len(outliers)}
+	if len(f.Mismatches) != 1 || f.Mismatches[0] != expected {
+		t.Fatalf("mismatches %+v do not match %+v", f.Mismatches, expected)
+	}
+
+	if err := index.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Mismatches) != 1 {
+		t.Fatalf("normalizing again repeated mismatches: %+v", f.Mismatches)
+	}
+}
+
+func TestNormalizeReportsTrailerMismatch(t *testing.T) {
+	f := &File{
+		Shards: []*Shard{{
+			Size: TagBytesForCRC + TagSize + 32,
+			Tag: Tag{
+				SourceSize:  100 | SourceSizeSinglePass,
+				ShardQuorum: 2,
+			},
+		}},
+		Trailer: &Trailer{SourceSize: 99},
+	}
+	index := Index{Files: map[string]*File{"single pass": f}}
+	if err := index.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	var mismatch *MismatchError
+	if !errors.As(f.Err(), &mismatch) {
+		t.Fatalf("error %q is not a mismatch", f.Err())
+	}
+	if mismatch.Parameter != MismatchTrailerSourceSize || mismatch.Expected != 100 || mismatch.Found != 99 {
+		t.Fatalf("unexpected mismatch: %+v", mismatch)
+	}
+	if len(f.Mismatches) != 1 || f.Mismatches[0] != *mismatch {
+		t.Fatalf("mismatch was not recorded: %+v", f.Mismatches)
+	}
+}
@@ -0,0 +1,295 @@
+package gopar3
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/dkotik/gopar3/telomeres"
+)
+
+// InflateOption configures [Inflate].
+type InflateOption func(*inflateOptions) error
+
+type inflateOptions struct {
+	telomeres   []telomeres.EncoderOption
+	jobs        int
+	singlePass  bool
+	memoryLimit uint64
+	codec       Codec
+	innerCode   bool
+	overwrite   bool
+	created     time.Time
+	progress    ProgressFunc
+	// previous is set by [Update]
+	previous *previousArchive
+}
+
+// WithInflateMemoryLimit caps the memory held by batch buffers.
+// Past the limit, buffers spill into a memory mapped temporary file.
+// See [swap.Pool].
+func WithInflateMemoryLimit(bytes uint64) InflateOption {
+	return func(o *inflateOptions) error {
+		o.memoryLimit = bytes
+		return nil
+	}
+}
+
+// WithInflateProgress reports the progress of [Inflate]
+// and [Update] to the given function.
+func WithInflateProgress(f ProgressFunc) InflateOption {
+	return func(o *inflateOptions) error {
+		o.progress = f
+		return nil
+	}
+}
+
+// WithSinglePass reads the source only once. The source checksum
+// is computed while shards are written and recorded in a [Trailer]
+// after the last batch. Without it, tags would need the checksum
+// before the first shard, which takes a separate pass over the source.
+func WithSinglePass() InflateOption {
+	return func(o *inflateOptions) error {
+		o.singlePass = true
+		return nil
+	}
+}
+
+// WithCodec computes parity shards with the given erasure code
+// instead of [CodecReedSolomon]. The codec is recorded in the tags,
+// so that [Restore] selects it.
+func WithCodec(codec Codec) InflateOption {
+	return func(o *inflateOptions) error {
+		if err := codec.validate(1, 0, LeopardShardSizeMultiple); err != nil {
+			return err
+		}
+		o.codec = codec
+		return nil
+	}
+}
+
+// WithInnerCode protects each shard with [ecc] codewords, which
+// correct up to [ecc.CorrectableErrors] damaged bytes in every
+// [ecc.CodewordSize] bytes before the shard checksum is verified.
+// Random bit errors no longer turn whole shards into erasures, while
+// parity shards still recover bursts and lost shards. Shards grow
+// by [ecc.ParitySize] bytes for every [ecc.DataSize] bytes.
+// [NewIndex] detects the inner code automatically.
+func WithInnerCode() InflateOption {
+	return func(o *inflateOptions) error {
+		o.innerCode = true
+		return nil
+	}
+}
+
+// WithOverwrite replaces an existing archive at the destination.
+// Without it, [Inflate] returns [ErrDestinationExists].
+func WithOverwrite() InflateOption {
+	return func(o *inflateOptions) error {
+		o.overwrite = true
+		return nil
+	}
+}
+
+// WithCreationTime records the given time in the [Header] instead
+// of the current time, so that inflating the same source with the same
+// options produces identical archives.
+func WithCreationTime(t time.Time) InflateOption {
+	return func(o *inflateOptions) error {
+		o.created = t
+		return nil
+	}
+}
+
+// WithInflateJobs sets the number of batches that are loaded
+// and encoded with parity concurrently. Defaults to [runtime.NumCPU].
+func WithInflateJobs(jobs int) InflateOption {
+	return func(o *inflateOptions) error {
+		if jobs < 1 {
+			return errors.New("number of inflate jobs must be greater than zero")
+		}
+		o.jobs = jobs
+		return nil
+	}
+}
+
+// WithByteStuffing frames shards using [telomeres.FramingStuffing]
+// instead of escaping marks. The overhead is bounded to one byte
+// for every [telomeres.StuffingGroupLimit] bytes regardless of data.
+// Cannot be combined with [WithSyncMarkers].
+func WithByteStuffing() InflateOption {
+	return func(o *inflateOptions) error {
+		o.telomeres = append(o.telomeres, telomeres.WithEncoderFraming(telomeres.FramingStuffing))
+		return nil
+	}
+}
+
+// WithTelomereMarks frames shards using the given mark and escape bytes
+// instead of [telomeres.DefaultMarks]. Data heavy in default marks,
+// like text logs or JSON, grows less after escaping with rare bytes.
+// The marks are recorded in the [Header], where [NewIndex] finds them.
+func WithTelomereMarks(mark, escape byte) InflateOption {
+	return func(o *inflateOptions) error {
+		marks := telomeres.Marks{Mark: mark, Escape: escape}
+		if err := marks.Validate(); err != nil {
+			return err
+		}
+		o.telomeres = append(o.telomeres, telomeres.WithEncoderMarks(marks))
+		return nil
+	}
+}
+
+func newInflateOptions(withOptions ...InflateOption) (*inflateOptions, error) {
+	o := &inflateOptions{jobs: runtime.NumCPU(), created: time.Now()}
+	for _, option := range withOptions {
+		if err := option(o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// WithSyncMarkers inserts telomere sync markers after every given
+// number of shard bytes. Damaged shards can then be narrowed
+// down to the sub-chunks that failed their checksums.
+// See [Shard.SubChunks].
+func WithSyncMarkers(interval int) InflateOption {
+	return func(o *inflateOptions) error {
+		if interval < 1 {
+			return errors.New("sync marker interval must be greater than zero")
+		}
+		o.telomeres = append(o.telomeres, telomeres.WithSyncInterval(interval))
+		return nil
+	}
+}
+
+// RestoreOption configures [Restore].
+type RestoreOption func(*restoreOptions) error
+
+type restoreOptions struct {
+	jobs        int
+	memoryLimit uint64
+	progress    ProgressFunc
+}
+
+// WithRestoreMemoryLimit caps the memory held by batch buffers.
+// Past the limit, buffers spill into a memory mapped temporary file.
+// See [swap.Pool].
+func WithRestoreMemoryLimit(bytes uint64) RestoreOption {
+	return func(o *restoreOptions) error {
+		o.memoryLimit = bytes
+		return nil
+	}
+}
+
+// WithRestoreProgress reports the progress
+// of [Restore] to the given function.
+func WithRestoreProgress(f ProgressFunc) RestoreOption {
+	return func(o *restoreOptions) error {
+		o.progress = f
+		return nil
+	}
+}
+
+// WithRestoreJobs sets the number of batches that are loaded
+// and reconstructed concurrently. Defaults to [runtime.NumCPU].
+func WithRestoreJobs(jobs int) RestoreOption {
+	return func(o *restoreOptions) error {
+		if jobs < 1 {
+			return errors.New("number of restore jobs must be greater than zero")
+		}
+		o.jobs = jobs
+		return nil
+	}
+}
+
+func newRestoreOptions(withOptions ...RestoreOption) (*restoreOptions, error) {
+	o := &restoreOptions{jobs: runtime.NumCPU()}
+	for _, option := range withOptions {
+		if err := option(o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// IndexOption configures [ScanIndex].
+type IndexOption func(*indexOptions) error
+
+type indexOptions struct {
+	// marks are read from the header or detected, if nil
+	marks *telomeres.Marks
+	carve bool
+	// unreadable ranges by clean source path
+	unreadable map[string][]ByteRange
+	progress   ProgressFunc
+}
+
+// WithIndexMarks decodes shards framed by the given mark and escape
+// bytes. Without it, the marks are read from the [Header] at the start
+// of each source or, if no intact copy remains, guessed by
+// [telomeres.DetectMarks], which can guess wrong for sparse data.
+func WithIndexMarks(mark, escape byte) IndexOption {
+	return func(o *indexOptions) error {
+		marks := telomeres.Marks{Mark: mark, Escape: escape}
+		if err := marks.Validate(); err != nil {
+			return err
+		}
+		o.marks = &marks
+		return nil
+	}
+}
+
+// WithIndexProgress reports the progress
+// of [ScanIndex] to the given function.
+func WithIndexProgress(f ProgressFunc) IndexOption {
+	return func(o *indexOptions) error {
+		o.progress = f
+		return nil
+	}
+}
+
+// WithCarving scans arbitrary containers for shards embedded in them.
+// Requires [WithIndexMarks]. See [NewCarvedIndex].
+func WithCarving() IndexOption {
+	return func(o *indexOptions) error {
+		o.carve = true
+		return nil
+	}
+}
+
+// WithUnreadable skips the ranges of the source, like the bad sectors
+// listed by [ReadMapfile], as if they failed to read. Chunks that
+// overlap them are not decoded, and shards that border them become
+// erasures. See [Index.Skipped].
+func WithUnreadable(source string, ranges ...ByteRange) IndexOption {
+	return func(o *indexOptions) error {
+		for _, r := range ranges {
+			if r.Offset < 0 || r.Size < 0 {
+				return fmt.Errorf("invalid unreadable range %s", r)
+			}
+		}
+		if o.unreadable == nil {
+			o.unreadable = make(map[string][]ByteRange)
+		}
+		source = filepath.Clean(source)
+		o.unreadable[source] = append(o.unreadable[source], ranges...)
+		return nil
+	}
+}
+
+func newIndexOptions(withOptions ...IndexOption) (*indexOptions, error) {
+	o := &indexOptions{}
+	for _, option := range withOptions {
+		if err := option(o); err != nil {
+			return nil, err
+		}
+	}
+	if o.carve && o.marks == nil {
+		// noise defeats detection
+		return nil, errors.New("telomere marks must be given for carving")
+	}
+	return o, nil
+}
@@ -0,0 +1,58 @@
+package gopar3
+
+import "context"
+
+// orderedBatch carries shards of a batch that was processed
+// out of order by one of the concurrent workers.
+type orderedBatch struct {
+	index  int
+	shards [][]byte
+	// buffers back the shards and are released after writing
+	buffers [][]byte
+	// unchanged is set when the encoded batch is copied from
+	// the previous version of the archive. See [Update].
+	unchanged *previousBatch
+	// copied is set when the batch was not encoded, because
+	// its parity shards were taken from the previous version.
+	copied bool
+}
+
+// dispatchBatches sends batch indexes to the workers. Each index
+// takes a token, which limits the number of batches held in memory
+// by the workers and the reorder buffer of [writeInOrder].
+func dispatchBatches(ctx context.Context, count int, tokens chan<- struct{}, out chan<- int) error {
+	defer close(out)
+	for i := 0; i < count; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case tokens <- struct{}{}:
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- i:
+		}
+	}
+	return nil
+}
+
+// writeInOrder puts processed batches back in order. A token
+// is released after each batch is written.
+func writeInOrder(batches <-chan orderedBatch, tokens <-chan struct{}, write func(batch orderedBatch) error) error {
+	next := 0
+	pending := make(map[int]orderedBatch)
+	for batch := range batches {
+		pending[batch.index] = batch
+		ready, ok := pending[next]
+		for ; ok; ready, ok = pending[next] {
+			delete(pending, next)
+			next++
+			if err := write(ready); err != nil {
+				return err
+			}
+			<-tokens
+		}
+	}
+	return nil
+}
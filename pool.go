@@ -0,0 +1,47 @@
+package gopar3
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// sourcePool shares one open file handle for each shard source.
+// Handles are read using [io.ReaderAt], which is safe for
+// concurrent use, so loaders do not re-open sources for every shard.
+type sourcePool struct {
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+func newSourcePool() *sourcePool {
+	return &sourcePool{files: make(map[string]*os.File)}
+}
+
+// Open returns the shared handle of the source,
+// opening it on the first call.
+func (p *sourcePool) Open(source string) (io.ReaderAt, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if f, ok := p.files[source]; ok {
+		return f, nil
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	p.files[source] = f
+	return f, nil
+}
+
+// Close releases all handles.
+func (p *sourcePool) Close() (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for source, f := range p.files {
+		err = errors.Join(err, f.Close())
+		delete(p.files, source)
+	}
+	return err
+}
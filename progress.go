@@ -0,0 +1,141 @@
+package gopar3
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ProgressInterval is the shortest time between two [Progress]
+// reports of the same operation. The final report is never skipped.
+const ProgressInterval = time.Second / 4
+
+// Progress is a snapshot of a long running operation:
+// [Inflate], [Update], [ScanIndex], or [Restore].
+type Progress struct {
+	Operation string
+	// BytesRead counts source bytes for [Inflate] and
+	// encoded shard bytes for [ScanIndex] and [Restore].
+	BytesRead int64
+	// BytesTotal is the expected value of BytesRead at completion.
+	BytesTotal     int64
+	BatchesWritten int `json:",omitempty"`
+	// BatchesCopied counts batches written by [Update] without
+	// encoding, from the previous version of the archive.
+	BatchesCopied int `json:",omitempty"`
+	BatchesTotal  int `json:",omitempty"`
+	ShardsScanned int `json:",omitempty"`
+	// Errors counts damaged shards encountered so far.
+	Errors  int
+	Elapsed time.Duration
+	Done    bool
+}
+
+// Fraction returns the completed portion of the operation
+// from zero to one, based on bytes if their total is known.
+func (p Progress) Fraction() float64 {
+	switch {
+	case p.Done:
+		return 1
+	case p.BytesTotal > 0:
+		return min(float64(p.BytesRead)/float64(p.BytesTotal), 1)
+	case p.BatchesTotal > 0:
+		return float64(p.BatchesWritten) / float64(p.BatchesTotal)
+	default:
+		return 0
+	}
+}
+
+// ETA estimates the remaining time assuming steady throughput.
+// Returns zero until some work is completed.
+func (p Progress) ETA() time.Duration {
+	fraction := p.Fraction()
+	if fraction <= 0 || fraction >= 1 {
+		return 0
+	}
+	return time.Duration(float64(p.Elapsed) * (1 - fraction) / fraction)
+}
+
+// progressReader reports bytes read through it.
+type progressReader struct {
+	io.Reader
+	reporter *progressReporter
+}
+
+func (r *progressReader) Read(b []byte) (n int, err error) {
+	n, err = r.Reader.Read(b)
+	r.reporter.Update(func(p *Progress) {
+		p.BytesRead += int64(n)
+	})
+	return n, err
+}
+
+// ProgressFunc receives [Progress] reports, see [WithInflateProgress],
+// [WithRestoreProgress], and [WithIndexProgress]. Calls are never
+// concurrent, but should return quickly, because the operation
+// waits for them.
+type ProgressFunc func(Progress)
+
+// ProgressChannel reports [Progress] into a channel. Reports are
+// dropped while the channel is full, except for the final one.
+func ProgressChannel(c chan<- Progress) ProgressFunc {
+	return func(p Progress) {
+		if p.Done {
+			c <- p
+			return
+		}
+		select {
+		case c <- p:
+		default:
+		}
+	}
+}
+
+// progressReporter accumulates updates from concurrent workers
+// and throttles reports. Nil reporter ignores updates.
+type progressReporter struct {
+	mu       sync.Mutex
+	report   ProgressFunc
+	progress Progress
+	started  time.Time
+	reported time.Time
+}
+
+func newProgressReporter(report ProgressFunc, operation string) *progressReporter {
+	if report == nil {
+		return nil
+	}
+	return &progressReporter{
+		report:   report,
+		progress: Progress{Operation: operation},
+		started:  time.Now(),
+	}
+}
+
+// Update changes the progress and reports it, unless
+// the previous report was too recent.
+func (r *progressReporter) Update(change func(p *Progress)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change(&r.progress)
+	if now := time.Now(); now.Sub(r.reported) >= ProgressInterval {
+		r.reported = now
+		r.progress.Elapsed = now.Sub(r.started)
+		r.report(r.progress)
+	}
+}
+
+// Finish sends the final report.
+func (r *progressReporter) Finish() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.progress.Done = true
+	r.progress.Elapsed = time.Since(r.started)
+	r.report(r.progress)
+}
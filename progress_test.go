@@ -0,0 +1,48 @@
+package gopar3
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressReporting(t *testing.T) {
+	reports := make(chan Progress, 1024)
+	testInflateAndRestoreWithOptions(t, roundTrip{progress: ProgressChannel(reports)})
+	close(reports)
+
+	final := make(map[string]Progress)
+	for report := range reports {
+		if report.Done {
+			final[report.Operation] = report
+		}
+	}
+
+	inflate := final["inflate"]
+	if inflate.BytesRead != 2*testFixtureSize || inflate.BytesTotal != inflate.BytesRead {
+		t.Fatalf("inflate read %d bytes out of %d", inflate.BytesRead, inflate.BytesTotal)
+	}
+	if inflate.BatchesWritten == 0 || inflate.BatchesWritten != inflate.BatchesTotal {
+		t.Fatalf("inflate wrote %d batches out of %d", inflate.BatchesWritten, inflate.BatchesTotal)
+	}
+	scan := final["index"]
+	if scan.ShardsScanned != inflate.BatchesTotal*8 || scan.Errors != 0 {
+		t.Fatalf("index scanned %d shards with %d errors", scan.ShardsScanned, scan.Errors)
+	}
+	if scan.BytesRead != scan.BytesTotal {
+		t.Fatalf("index read %d bytes out of %d", scan.BytesRead, scan.BytesTotal)
+	}
+	restore := final["restore"]
+	if restore.BatchesWritten != inflate.BatchesTotal || restore.Fraction() != 1 || restore.ETA() != 0 {
+		t.Fatalf("restore wrote %d batches out of %d", restore.BatchesWritten, restore.BatchesTotal)
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	p := Progress{BytesRead: 25, BytesTotal: 100, Elapsed: time.Minute}
+	if eta := p.ETA(); eta != 3*time.Minute {
+		t.Fatalf("estimated %s instead of 3m0s", eta)
+	}
+	if eta := (Progress{Elapsed: time.Minute}).ETA(); eta != 0 {
+		t.Fatalf("estimated %s without any progress", eta)
+	}
+}
@@ -9,11 +9,16 @@ import (
 	"hash/crc32"
 	"io"
 
+	"github.com/dkotik/gopar3/ecc"
 	"github.com/dkotik/gopar3/telomeres"
 )
 
 var (
 	ErrShardTooSmall = errors.New("there are not enough bytes to decode the shard checksum and tag")
+
+	// errHeaderChunk is returned by [Reader.nextChunk] for chunks
+	// that carry a [Header] instead of a shard.
+	errHeaderChunk = errors.New("header chunk")
 )
 
 type CheckSumError struct {
@@ -27,29 +32,62 @@ func (e *CheckSumError) Error() string {
 
 type Reader struct {
 	*telomeres.Decoder
-	Source   string
+	Source string
+	// InnerCode decodes shards written with [WithInnerCode]
+	// and corrects their errors before the checksum is verified.
+	InnerCode bool
+	// Headers are the valid header chunks skipped by [Reader.NextShard].
+	// Consecutive copies of the same header are recorded once.
+	Headers  []SourceHeader
+	inner    *ecc.Reader
 	buffer   []byte
 	shardCRC hash.Hash32
 }
 
-func NewReader(source string, r io.ReadSeeker) *Reader {
+func NewReader(source string, r io.Reader, withOptions ...telomeres.DecoderOption) *Reader {
+	decoder := telomeres.NewDecoder(r, withOptions...)
 	return &Reader{
 		Source:   source,
-		Decoder:  telomeres.NewDecoder(r),
+		Decoder:  decoder,
+		inner:    ecc.NewReader(decoder),
 		buffer:   make([]byte, 32*1024),
 		shardCRC: crc32.New(castagnoliTable),
 	}
 }
 
+// NextShard decodes the next shard and writes its data. Header
+// chunks are skipped and recorded in [Reader.Headers].
 func (r *Reader) NextShard(ctx context.Context, w io.Writer) (s *Shard, err error) {
+	for {
+		if s, err = r.nextChunk(ctx, w); !errors.Is(err, errHeaderChunk) {
+			return s, err
+		}
+	}
+}
+
+func (r *Reader) nextChunk(ctx context.Context, w io.Writer) (s *Shard, err error) {
 	s = &Shard{
 		Source: r.Source,
 	}
+	if marks := r.Decoder.Marks(); marks != telomeres.DefaultMarks {
+		s.Telomeres = &marks
+	}
+	s.Framing = r.Decoder.Framing()
+	s.InnerCode = r.InnerCode
+	var chunk io.Reader = r.Decoder
+	if r.InnerCode {
+		r.inner.Reset(r.Decoder)
+		chunk = r.inner
+	}
 	defer func() {
+		if r.InnerCode {
+			s.Corrected = r.inner.Corrected()
+		}
 		var cerr error
-		if s.LastByte, cerr = r.Decoder.Cursor(); err != nil {
+		if s.LastByte, cerr = r.Decoder.Cursor(); cerr != nil {
 			err = errors.Join(err, cerr)
 		}
+		s.SubChunks = r.Decoder.SubChunks()
 		if err != nil {
 			s.Error = err.Error()
 		} else if realSum := r.shardCRC.Sum32(); realSum != s.CastagnoliSum {
@@ -65,7 +103,11 @@ func (r *Reader) NextShard(ctx context.Context, w io.Writer) (s *Shard, err erro
 	}
 
 	var werr error
-	n, err := io.ReadFull(r.Decoder, r.buffer)
+	n, err := io.ReadFull(chunk, r.buffer)
+	if err == telomeres.ErrBoundary && isHeaderChunk(r.buffer[:n]) {
+		r.recordHeader(s.FirstByte, r.buffer[:n])
+		return s, errHeaderChunk
+	}
 	if n < TagSize+TagBytesForCRC {
 		return s, ErrShardTooSmall
 	}
@@ -75,11 +117,15 @@ func (r *Reader) NextShard(ctx context.Context, w io.Writer) (s *Shard, err erro
 	if _, werr = r.shardCRC.Write(r.buffer[TagBytesForCRC:n]); werr != nil {
 		return s, werr
 	}
-	s.Tag = NewTagFromBytes(r.buffer[TagBytesForCRC : TagBytesForCRC+TagSize])
+	s.Tag = NewTagFromBytes(r.buffer[TagBytesForCRC:n])
+	header := TagBytesForCRC + s.Tag.encodedSize()
+	if n < header {
+		return s, ErrShardTooSmall
+	}
 	switch err {
 	case nil:
 	case io.EOF, io.ErrUnexpectedEOF, telomeres.ErrBoundary:
-		if n, err = w.Write(r.buffer[TagBytesForCRC+TagSize : n]); err != nil {
+		if n, err = w.Write(r.buffer[header:n]); err != nil {
 			return s, err
 		}
 		fallthrough
@@ -87,8 +133,8 @@ func (r *Reader) NextShard(ctx context.Context, w io.Writer) (s *Shard, err erro
 		return s, err
 	}
 
-	n, err = w.Write(r.buffer[TagBytesForCRC+TagSize : n])
-	// log.Fatalf("%s", r.buffer[TagBytesForCRC+TagSize:n])
+	n, err = w.Write(r.buffer[header:n])
+	// log.Fatalf("%s", r.buffer[header:n])
 	if err != nil {
 		return s, err
 	}
@@ -99,7 +145,7 @@ func (r *Reader) NextShard(ctx context.Context, w io.Writer) (s *Shard, err erro
 			return s, ctx.Err()
 		default:
 		}
-		n, err = io.ReadFull(r.Decoder, r.buffer)
+		n, err = io.ReadFull(chunk, r.buffer)
 		if n > 0 {
 			s.Size += int64(n)
 			if _, werr = w.Write(r.buffer[:n]); werr != nil {
@@ -118,3 +164,20 @@ func (r *Reader) NextShard(ctx context.Context, w io.Writer) (s *Shard, err erro
 		return s, err
 	}
 }
+
+// recordHeader keeps the header, unless it is damaged
+// or repeats the previous one.
+func (r *Reader) recordHeader(firstByte int64, b []byte) {
+	header, err := NewHeaderFromBytes(b)
+	if err != nil {
+		return // damaged copy
+	}
+	if n := len(r.Headers); n > 0 && r.Headers[n-1].Header == header {
+		return
+	}
+	r.Headers = append(r.Headers, SourceHeader{
+		Source:    r.Source,
+		FirstByte: firstByte,
+		Header:    header,
+	})
+}
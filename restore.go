@@ -9,22 +9,38 @@ import (
 	"log"
 	"slices"
 
-	"github.com/klauspost/reedsolomon"
+	"github.com/dkotik/gopar3/ecc"
+	"github.com/dkotik/gopar3/swap"
 	"golang.org/x/sync/errgroup"
 )
 
 // Restore writes recovered contents of a file using shards
-// of a normalized [Index].
-func Restore(ctx context.Context, w io.Writer, f *File) (err error) {
-	if f.Error != "" {
-		return errors.New(f.Error)
+// of a normalized [Index]. Batches are loaded and reconstructed
+// concurrently and written out in order. See [WithRestoreJobs].
+func Restore(ctx context.Context, w io.Writer, f *File, withOptions ...RestoreOption) (err error) {
+	options, err := newRestoreOptions(withOptions...)
+	if err != nil {
+		return err
 	}
+	sources := newSourcePool()
+	defer func() {
+		err = errors.Join(err, sources.Close())
+	}()
 
+	if _, err = f.heal(ctx, sources); err != nil {
+		return err
+	}
+	if err = f.Err(); err != nil {
+		return err
+	}
+
+	quorum := int(f.Quorum)
+	shardCount := f.shardCount()
 	batches := make([][]*Shard, f.Batches)
 	lastBatch := int(f.Batches - 1)
 	batch := 0
 	for _, shard := range f.Shards {
-		if shard.Error != "" {
+		if shard.Error != "" || int(shard.Tag.ShardOrder) >= shardCount {
 			continue
 		}
 		batch = int(shard.Tag.ShardBatch)
@@ -37,9 +53,7 @@ func Restore(ctx context.Context, w io.Writer, f *File) (err error) {
 	}
 	// panic(fmt.Sprintf("%+v", batches))
 
-	quorum := int(f.Quorum)
 	available := 0
-	mostShards := 0
 	for i, batch := range batches {
 		available = len(batch)
 		if available < quorum {
@@ -56,79 +70,123 @@ func Restore(ctx context.Context, w io.Writer, f *File) (err error) {
 			}
 			return 0
 		})
-		if available > mostShards {
-			mostShards = available
-		}
 	}
 
-	forReconstruction := make(chan [][]byte, 4)
-	wg, ctx := errgroup.WithContext(ctx)
-	wg.Go(func() (err error) {
-		defer close(forReconstruction)
-		for _, batch := range batches {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			default:
-			}
+	// chunks carry the checksum and the tag with any extension,
+	// and may have
+	// one extra byte before realignment
+	chunkSize := int(f.ShardSize) + TagBytesForCRC + TagSize + TagExtensionSize + 1
+	if slices.ContainsFunc(f.Shards, func(s *Shard) bool { return s.InnerCode }) {
+		chunkSize = ecc.EncodedSize(chunkSize) // decoded in place
+	}
+	pool, err := swap.NewPool(chunkSize, options.memoryLimit)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, pool.Close())
+	}()
 
-			shards := make([][]byte, mostShards)
+	reporter := newProgressReporter(options.progress, "restore")
+	reporter.Update(func(p *Progress) {
+		p.BatchesTotal = len(batches)
+		for _, batch := range batches {
 			for _, shard := range batch {
-				shards[int(shard.ShardOrder)], err = shard.Load(ctx)
-				if err != nil {
-					return err
-				}
-
-				// fmt.Printf("%d -------------------------------\n", len(shards[i]))
-				// fmt.Println(string(shards[i]))
-				// if len(shards[i]) == 0 {
-				// 	// od -j 1024 -N 16
-				// 	fmt.Printf("check range: od -j %d -N %d", shard.CursorStart, shard.CursorEnd-shard.CursorStart)
-				// 	panic("loaded an empty shard without an error")
-				// }
-			}
-
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case forReconstruction <- shards:
+				p.BytesTotal += shard.LastByte - shard.FirstByte
 			}
 		}
-		return nil
 	})
 
-	forWriting := make(chan [][]byte, 4)
-	wg.Go(func() (err error) {
+	tokens := make(chan struct{}, 2*options.jobs)
+	forLoading := make(chan int)
+	forWriting := make(chan orderedBatch, options.jobs)
+	wg, ctx := errgroup.WithContext(ctx)
+	wg.Go(func() error {
+		return dispatchBatches(ctx, len(batches), tokens, forLoading)
+	})
+
+	wg.Go(func() error {
 		defer close(forWriting)
-		rs, err := reedsolomon.New(quorum, mostShards-quorum)
-		if err != nil {
-			return err
-		}
-		for shards := range forReconstruction {
-			if err = rs.ReconstructData(shards); err != nil {
-				// for i, shard := range shards {
-				// 	log.Printf("%d: %s", i, shard)
-				// }
-				return err
-			}
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case forWriting <- shards[:quorum]:
-			}
+		workers, ctx := errgroup.WithContext(ctx)
+		for range options.jobs {
+			workers.Go(func() (err error) {
+				rs, err := f.Codec.newEncoder(quorum, shardCount-quorum)
+				if err != nil {
+					return err
+				}
+				for i := range forLoading {
+					shards := make([][]byte, shardCount)
+					buffers := make([][]byte, 0, shardCount)
+					for _, shard := range batches[i] {
+						r, err := sources.Open(shard.Source)
+						if err != nil {
+							return err
+						}
+						buffer, err := pool.Get()
+						if err != nil {
+							return err
+						}
+						buffers = append(buffers, buffer)
+						b, err := shard.load(ctx, r, buffer)
+						if err != nil && !errors.Is(err, ecc.ErrUncorrectable) {
+							return err
+						}
+						damaged := err != nil || int64(len(b)) != f.ShardSize
+						reporter.Update(func(p *Progress) {
+							p.BytesRead += shard.LastByte - shard.FirstByte
+							p.ShardsScanned++
+							if damaged {
+								p.Errors++
+							}
+						})
+						if damaged {
+							// shard was damaged after indexing, treat as erasure
+							continue
+						}
+						shards[int(shard.ShardOrder)] = b
+					}
+					for j, shard := range shards[:quorum] {
+						if shard != nil {
+							continue
+						}
+						// reconstruction fills empty buffers with enough capacity
+						buffer, err := pool.Get()
+						if err != nil {
+							return err
+						}
+						buffers = append(buffers, buffer)
+						shards[j] = buffer[:0]
+					}
+					if err = rs.ReconstructData(shards); err != nil {
+						return err
+					}
+
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case forWriting <- orderedBatch{index: i, shards: shards[:quorum], buffers: buffers}:
+					}
+				}
+				return nil
+			})
 		}
-		return nil
+		return workers.Wait()
 	})
 
 	wg.Go(func() (err error) {
 		var (
 			written    int64
 			writeLimit = int64(f.Size)
-			padding    int
-			n          int
 			crc        = crc32.New(castagnoliTable)
 		)
-		for shards := range forWriting {
+		writeBatch := func(batch orderedBatch) (err error) {
+			defer func() {
+				for _, buffer := range batch.buffers {
+					pool.Put(buffer)
+				}
+			}()
+			var padding, n int
+			shards := batch.shards
 			// padding calculations assume that all shards are the same size
 			n = len(shards[0]) // shard size here for determining padding
 			if padding = int(written) + (len(shards) * n) - int(writeLimit); padding > 0 {
@@ -148,17 +206,42 @@ func Restore(ctx context.Context, w io.Writer, f *File) (err error) {
 				}
 				written += int64(n)
 			}
+			reporter.Update(func(p *Progress) {
+				p.BatchesWritten++
+			})
+			return nil
+		}
+
+		if err = writeInOrder(forWriting, tokens, writeBatch); err != nil {
+			return err
 		}
 
 		if written != writeLimit {
 			return fmt.Errorf("the number of written bytes %d does not match expected file size %d", written, f.Size)
 		}
-		if crc.Sum32() != f.CastagnoliSum {
+		if !f.Unverified && crc.Sum32() != f.CastagnoliSum {
 			log.Print(crc.Sum32(), f.CastagnoliSum)
 			return errors.New("circular redundancy check does not match the expected value; the file is corrupt and cannot be recovered")
 		}
+		reporter.Finish()
 		return nil
 	})
 
 	return wg.Wait()
 }
+
+// shardCount returns the number of shards in each batch. Archives
+// that do not record parity are assumed to have as many shards
+// as the highest order of a healthy shard requires.
+func (f *File) shardCount() int {
+	count := int(f.Quorum) + int(f.Parity)
+	if f.Parity > 0 {
+		return count
+	}
+	for _, shard := range f.Shards {
+		if shard.Error == "" {
+			count = max(count, int(shard.Tag.ShardOrder)+1)
+		}
+	}
+	return count
+}
@@ -0,0 +1,250 @@
+package gopar3
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dkotik/gopar3/ecc"
+	"github.com/dkotik/gopar3/telomeres"
+)
+
+func TestRestoreJobs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	destination := t.TempDir()
+	if err := Inflate(ctx, destination, "README.md", 3, 2, 32); err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := filepath.Glob(filepath.Join(destination, "*.gopar3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, jobs := range []int{1, 3, 16} {
+		index, err := NewIndex(ctx, outputs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range index.Files {
+			b := &bytes.Buffer{}
+			if err = Restore(ctx, b, file, WithRestoreJobs(jobs)); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b.Bytes(), expected) {
+				t.Fatalf("file restored with %d jobs does not match the original", jobs)
+			}
+		}
+	}
+
+	if err = Restore(ctx, &bytes.Buffer{}, &File{}, WithRestoreJobs(0)); err == nil {
+		t.Fatal("restore accepted zero jobs")
+	}
+}
+
+func TestInflateAndRestoreWithMemoryLimit(t *testing.T) {
+	// one byte limit spills every buffer
+	testInflateAndRestoreWithOptions(t, roundTrip{
+		inflate: []InflateOption{WithInflateMemoryLimit(1), WithInflateJobs(3)},
+		restore: []RestoreOption{WithRestoreMemoryLimit(1), WithRestoreJobs(3)},
+	})
+}
+
+func TestRestoreWithRecordedParity(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	expected, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		quorum uint16
+		parity uint16
+		lost   func(order, quorum, parity int) bool
+	}{
+		{name: "highest parity shards lost", quorum: 3, parity: 2, lost: func(order, quorum, parity int) bool {
+			return order == quorum+parity-1
+		}},
+		{name: "lower parity shards lost", quorum: 3, parity: 2, lost: func(order, quorum, parity int) bool {
+			return order == quorum-1 || order == quorum
+		}},
+		{name: "parity over 128", quorum: 2, parity: 200, lost: func(order, quorum, parity int) bool {
+			return order < quorum || order > quorum+parity/2
+		}},
+		{name: "all shards", quorum: 1, parity: ShardLimit - 1, lost: func(order, quorum, parity int) bool {
+			return order < quorum+parity-1
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "archive.gopar3")
+			if err := Inflate(ctx, archive, "README.md", c.quorum, c.parity, 1024); err != nil {
+				t.Fatal(err)
+			}
+			index, err := NewIndex(ctx, archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range index.Files {
+				if file.Parity != c.parity {
+					t.Fatalf("recorded parity is %d instead of %d", file.Parity, c.parity)
+				}
+				for _, shard := range file.Shards {
+					if c.lost(int(shard.Tag.ShardOrder), int(c.quorum), int(c.parity)) {
+						shard.Error = "lost"
+					}
+				}
+				b := &bytes.Buffer{}
+				if err = Restore(ctx, b, file); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(b.Bytes(), expected) {
+					t.Fatal("restored file does not match the original")
+				}
+			}
+		})
+	}
+
+	if err := Inflate(ctx, t.TempDir(), "README.md", 128, 128, 1024); err == nil {
+		t.Fatal("inflated more shards than a tag can order")
+	}
+}
+
+func TestRestoreWideBatches(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	directory := t.TempDir()
+	original := make([]byte, 300*64*2+100)
+	rand.New(rand.NewSource(1)).Read(original)
+	source := filepath.Join(directory, "source.bin")
+	if err := os.WriteFile(source, original, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(directory, "archive.gopar3")
+	if err := Inflate(ctx, archive, source, 300, 200, 63, WithCodec(CodecLeopard)); err == nil {
+		t.Fatal("leopard accepted shards that are not a multiple of 64 bytes")
+	}
+	if err := Inflate(ctx, archive, source, 300, 200, 64, WithCodec(CodecLeopard)); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := NewIndex(ctx, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Files) != 1 {
+		t.Fatalf("found %d files instead of one", len(index.Files))
+	}
+	for _, file := range index.Files {
+		if file.Codec != CodecLeopard || file.Quorum != 300 || file.Parity != 200 {
+			t.Fatalf("recorded %s with quorum %d and parity %d", file.Codec, file.Quorum, file.Parity)
+		}
+		if file.Error != "" {
+			t.Fatal(file.Error)
+		}
+		for _, shard := range file.Shards {
+			if shard.Tag.ShardOrder%5 < 2 { // lose two of every five shards
+				shard.Error = "lost"
+			}
+		}
+		b := &bytes.Buffer{}
+		if err = Restore(ctx, b, file); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b.Bytes(), original) {
+			t.Fatal("restored file does not match the original")
+		}
+	}
+}
+
+func TestRestoreWithInnerCode(t *testing.T) {
+	testInflateAndRestore(t, WithInnerCode(), WithByteStuffing())
+
+	var flipped []int
+	testInflateAndRestoreWithOptions(t, roundTrip{
+		inflate: []InflateOption{WithInnerCode()},
+		sources: func(t *testing.T, archive string) []string {
+			b, err := os.ReadFile(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i < len(b); i += 97 { // one byte error in every codeword
+				if damaged := b[i] ^ 0x01; !isMarkOrEscape(b[i-1]) && !isMarkOrEscape(b[i]) && !isMarkOrEscape(damaged) {
+					b[i] = damaged
+					flipped = append(flipped, i)
+				}
+			}
+			if err = os.WriteFile(archive, b, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return []string{archive}
+		},
+		prepare: func(t *testing.T, index *Index, _ []string) {
+			for _, file := range index.Files {
+				first := slices.MinFunc(file.Shards, func(a, b *Shard) int {
+					return cmp.Compare(a.FirstByte, b.FirstByte)
+				}).FirstByte
+				inShards := len(flipped) - sort.SearchInts(flipped, int(first))
+				if corrected := index.Health()[0].Corrected; corrected != inShards {
+					t.Fatalf("corrected %d bytes instead of %d", corrected, inShards)
+				}
+				for _, shard := range file.Shards {
+					if shard.Error != "" || !shard.InnerCode {
+						t.Fatalf("shard %d of batch %d was not corrected: %s", shard.Tag.ShardOrder, shard.Tag.ShardBatch, shard.Error)
+					}
+					if shard.Tag.ShardOrder >= file.Quorum { // only corrected data shards remain
+						shard.Error = "lost"
+					}
+				}
+			}
+		},
+	})
+}
+
+func TestRestoreErasesUncorrectableShards(t *testing.T) {
+	testInflateAndRestoreWithOptions(t, roundTrip{
+		inflate: []InflateOption{WithInnerCode()},
+		prepare: func(t *testing.T, index *Index, sources []string) {
+			b, err := os.ReadFile(sources[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range index.Files {
+				var shard *Shard
+				for _, shard = range file.Shards {
+					if shard.Tag.ShardBatch == 0 && shard.Tag.ShardOrder == 0 {
+						break
+					}
+				}
+				// damaged after indexing beyond what the inner code corrects
+				damaged := 0
+				for i := shard.FirstByte + 16; damaged <= ecc.CorrectableErrors; i++ {
+					if !isMarkOrEscape(b[i]) && !isMarkOrEscape(b[i-1]) && b[i] != 'x' {
+						b[i] = 'x'
+						damaged++
+					}
+				}
+			}
+			if err = os.WriteFile(sources[0], b, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		},
+	})
+}
+
+func isMarkOrEscape(b byte) bool {
+	return b == telomeres.Mark || b == telomeres.Escape
+}
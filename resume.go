@@ -0,0 +1,65 @@
+package gopar3
+
+import (
+	"context"
+	"io"
+	"slices"
+
+	"github.com/dkotik/gopar3/telomeres"
+)
+
+// resumePoint scans a partial output of [Inflate] for batches of the
+// given tag that were written intact and in order. Returns the number
+// of complete batches and the offset right after the last of them.
+// The scan stops at the first shard that does not follow, which is
+// usually the batch that was being written when inflate was interrupted.
+func resumePoint(
+	ctx context.Context,
+	r io.Reader,
+	tag Tag,
+	shards int,
+	shardSize int,
+	marks telomeres.Marks,
+	framing telomeres.Framing,
+	innerCode bool,
+) (batches int, offset int64, err error) {
+	reader := NewReader("", r,
+		telomeres.WithDecoderMarks(marks),
+		telomeres.WithDecoderFraming(framing),
+	)
+	reader.InnerCode = innerCode
+
+	order := 0
+	for {
+		shard, err := reader.NextShard(ctx, io.Discard)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return 0, 0, ctxErr
+			}
+			break // end of the partial output
+		}
+		if shard.Error != "" ||
+			slices.ContainsFunc(shard.SubChunks, isDamaged) ||
+			shard.Tag.SourceCRC != tag.SourceCRC ||
+			shard.Tag.SourceSize != tag.SourceSize ||
+			shard.Tag.ShardQuorum != tag.ShardQuorum ||
+			shard.Tag.ShardBatch != uint16(batches) || // wraps like the tagger
+			int(shard.Tag.ShardOrder) != order ||
+			shard.payloadSize() != int64(shardSize) {
+			break
+		}
+		if order++; order == shards {
+			order = 0
+			batches++
+			offset = shard.LastByte
+		}
+	}
+	return batches, offset, nil
+}
+
+// isDamaged reports sub-chunks that do not match the checksum of their
+// sync marker. The shard checksum does not cover sync markers, so their
+// damage would otherwise be kept in the resumed output.
+func isDamaged(s telomeres.SubChunk) bool {
+	return !s.Intact
+}
@@ -0,0 +1,62 @@
+package gopar3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInflateResumesPartialOutput(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	for name, options := range map[string][]InflateOption{
+		"escape":   nil,
+		"sync":     {WithSyncMarkers(16)},
+		"stuffing": {WithByteStuffing()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			destination := filepath.Join(t.TempDir(), "complete.gopar3")
+			if err := Inflate(ctx, destination, "README.md", 3, 2, 32, options...); err != nil {
+				t.Fatal(err)
+			}
+			expected, err := os.ReadFile(destination)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			corrupted := bytes.Clone(expected)
+			corrupted[len(corrupted)/2] ^= 0xff
+			partials := map[string][]byte{
+				"empty":     nil,
+				"garbage":   []byte("not an inflated file"),
+				"complete":  expected,
+				"corrupted": corrupted,
+			}
+			for _, fraction := range []int{1, 3, 5, 7} {
+				partials[fmt.Sprintf("cut at %d/8", fraction)] = expected[:len(expected)*fraction/8]
+			}
+
+			for cut, partial := range partials {
+				resumed := filepath.Join(t.TempDir(), "resumed.gopar3")
+				if err = os.WriteFile(resumed+PartialSuffix, partial, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if err = Inflate(ctx, resumed, "README.md", 3, 2, 32, options...); err != nil {
+					t.Fatal(cut, err)
+				}
+				result, err := os.ReadFile(resumed)
+				if err != nil {
+					t.Fatal(cut, err)
+				}
+				if !bytes.Equal(result, expected) {
+					t.Errorf("%s: resumed output differs from uninterrupted output", cut)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package gopar3
+
+import (
+	"errors"
+	"io"
+)
+
+// ReadErrorSkipSize is the alignment of the regions skipped past read
+// errors while scanning sources. A page covers the sectors of most media.
+const ReadErrorSkipSize = 4096
+
+// errUnreadable is recorded for the regions given by [WithUnreadable].
+var errUnreadable = errors.New("marked unreadable")
+
+// SkippedRegion is a part of a source that could not be read.
+type SkippedRegion struct {
+	Source string
+	ByteRange
+	Error string
+}
+
+// skippingReader reads a source with positional reads. Regions that
+// fail to read are skipped to the next [ReadErrorSkipSize] boundary
+// and read as filler bytes, so that positions after them remain true.
+type skippingReader struct {
+	r      io.ReaderAt
+	source string
+	// size is zero for sources that do not report it, like block devices
+	size    int64
+	offset  int64
+	fill    byte
+	skipped []SkippedRegion
+}
+
+func (s *skippingReader) Read(b []byte) (n int, err error) {
+	if s.size > 0 {
+		if s.offset >= s.size {
+			return 0, io.EOF
+		}
+		b = b[:min(int64(len(b)), s.size-s.offset)]
+	}
+	if skipped := s.within(s.offset); skipped != nil {
+		n = int(min(int64(len(b)), skipped.Offset+skipped.Size-s.offset))
+		for i := range b[:n] {
+			b[i] = s.fill
+		}
+		s.offset += int64(n)
+		return n, nil
+	}
+	for _, skipped := range s.skipped {
+		if skipped.Offset > s.offset && skipped.Offset < s.offset+int64(len(b)) {
+			b = b[:skipped.Offset-s.offset] // filled by the next read
+		}
+	}
+
+	n, err = s.r.ReadAt(b, s.offset)
+	s.offset += int64(n)
+	switch {
+	case err == nil || err == io.EOF:
+		return n, err
+	case n > 0:
+		return n, nil // the error repeats on the next read
+	}
+	end := (s.offset/ReadErrorSkipSize + 1) * ReadErrorSkipSize
+	if s.size > 0 {
+		end = min(end, s.size)
+	}
+	s.skip(ByteRange{Offset: s.offset, Size: end - s.offset}, err)
+	return s.Read(b)
+}
+
+// skip records the region, merging it with the previous
+// one, if they are adjacent.
+func (s *skippingReader) skip(r ByteRange, err error) {
+	if n := len(s.skipped); n > 0 {
+		last := &s.skipped[n-1]
+		if last.Offset+last.Size == r.Offset && last.Error == err.Error() {
+			last.Size += r.Size
+			return
+		}
+	}
+	s.skipped = append(s.skipped, SkippedRegion{
+		Source:    s.source,
+		ByteRange: r,
+		Error:     err.Error(),
+	})
+}
+
+func (s *skippingReader) within(offset int64) *ByteRange {
+	for i := range s.skipped {
+		if s.skipped[i].overlaps(offset, offset+1) {
+			return &s.skipped[i].ByteRange
+		}
+	}
+	return nil
+}
+
+func (s *skippingReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		if s.size == 0 {
+			return s.offset, errors.New("source size is unknown")
+		}
+		offset += s.size
+	default:
+		return s.offset, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return s.offset, errors.New("negative position")
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+// markSkipped turns the shard into an erasure, if it overlaps
+// or borders any of the skipped regions, which may have cut it.
+// Returns false for a cut shard that failed its checksum, because
+// its tag cannot be trusted to place it in any file.
+func (s *skippingReader) markSkipped(shard *Shard) bool {
+	for _, skipped := range s.skipped {
+		if skipped.overlaps(shard.FirstByte-1, shard.LastByte+1) {
+			if shard.Error != "" {
+				return false
+			}
+			shard.markUnreadable(skipped.ByteRange)
+			return true
+		}
+	}
+	return true
+}
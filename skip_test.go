@@ -0,0 +1,119 @@
+package gopar3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// faultyReaderAt fails to read the bytes within the range.
+type faultyReaderAt struct {
+	*bytes.Reader
+	bad ByteRange
+}
+
+func (f faultyReaderAt) ReadAt(b []byte, offset int64) (int, error) {
+	if f.bad.overlaps(offset, offset+1) {
+		return 0, errors.New("input/output error")
+	}
+	if f.bad.overlaps(offset, offset+int64(len(b))) {
+		n, _ := f.Reader.ReadAt(b[:f.bad.Offset-offset], offset)
+		return n, errors.New("input/output error")
+	}
+	return f.Reader.ReadAt(b, offset)
+}
+
+func TestScanSourceSkipsReadErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	directory := t.TempDir()
+	original := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(original)
+	source := filepath.Join(directory, "source.bin")
+	if err := os.WriteFile(source, original, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(directory, "archive.gopar3")
+	if err := Inflate(ctx, archive, source, 4, 2, 256); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad := ByteRange{Offset: 20000, Size: 10}
+	r := &skippingReader{
+		r:      faultyReaderAt{Reader: bytes.NewReader(b), bad: bad},
+		source: archive,
+		size:   int64(len(b)),
+	}
+	index := Index{Files: make(map[string]*File)}
+	var last int64
+	err = scanSource(ctx, r, &indexOptions{}, newProgressReporter(nil, "index"), &[]SourceHeader{}, func(shard *Shard) {
+		last = max(last, shard.LastByte)
+		file, ok := index.Files[shard.Differentiator()]
+		if !ok {
+			file = &File{}
+			index.Files[shard.Differentiator()] = file
+		}
+		file.Shards = append(file.Shards, shard)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = index.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	skipped := ByteRange{Offset: bad.Offset, Size: ReadErrorSkipSize - bad.Offset%ReadErrorSkipSize}
+	if len(r.skipped) != 1 || r.skipped[0].ByteRange != skipped {
+		t.Fatalf("unexpected skipped regions: %v", r.skipped)
+	}
+	if last < int64(len(b))-ReadErrorSkipSize {
+		t.Fatalf("scan stopped at byte %d of %d", last, len(b))
+	}
+	if len(index.Files) != 1 {
+		t.Fatalf("found %d files instead of one", len(index.Files))
+	}
+	clean, err := NewIndex(ctx, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for differentiator, file := range index.Files {
+		readable := 0
+		for _, shard := range file.Shards {
+			if shard.Unreadable == nil {
+				readable++
+			}
+		}
+		if readable >= len(clean.Files[differentiator].Shards) {
+			t.Fatal("shards cut by the skipped region were not erased")
+		}
+		restored := &bytes.Buffer{}
+		if err = Restore(ctx, restored, file); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(restored.Bytes(), original) {
+			t.Fatal("restored file does not match the original")
+		}
+	}
+}
+
+func TestSkippingReaderFillsUnreadable(t *testing.T) {
+	r := &skippingReader{r: bytes.NewReader([]byte("0123456789")), size: 10, fill: '#'}
+	r.skip(ByteRange{Offset: 3, Size: 4}, errUnreadable)
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "012####789" {
+		t.Fatalf("unexpected bytes: %q", b)
+	}
+}
@@ -0,0 +1,173 @@
+package swap
+
+import (
+	"errors"
+	"sync"
+)
+
+var errSpillUnsupported = errors.New("spill file is not supported on this platform")
+
+// spillGrowth is the number of buffers added to the spill file
+// every time it runs out of free buffers.
+const spillGrowth = 16
+
+// Pool hands out byte slices of the same size and recycles them.
+// It accounts only for the memory of its own buffers.
+// Buffers are allocated on the heap until the memory limit is reached.
+// Past the limit, buffers are carved out of a memory mapped temporary
+// file, which the operating system can page out to disk. Platforms
+// without memory mapping keep allocating on the heap.
+type Pool struct {
+	mu       sync.Mutex
+	size     int
+	limit    uint64
+	inMemory uint64
+	free     [][]byte
+	spilled  [][]byte
+	// owned tracks every buffer handed out by the pool by its first
+	// byte. The value is true for buffers backed by the spill file.
+	owned map[*byte]bool
+	spill *spillFile
+	dir   string
+}
+
+// PoolOption configures the [Pool].
+type PoolOption func(*Pool) error
+
+// WithSpillDirectory sets the directory for the temporary spill file.
+// Defaults to [os.TempDir].
+func WithSpillDirectory(dir string) PoolOption {
+	return func(p *Pool) error {
+		if dir == "" {
+			return errors.New("spill directory cannot be empty")
+		}
+		p.dir = dir
+		return nil
+	}
+}
+
+// NewPool sets up a pool of buffers of a given size. Memory limit
+// of zero keeps all buffers in memory.
+func NewPool(bufferSize int, memoryLimitInBytes uint64, withOptions ...PoolOption) (*Pool, error) {
+	if bufferSize < 1 {
+		return nil, errors.New("buffer size must be greater than zero")
+	}
+	p := &Pool{
+		size:  bufferSize,
+		limit: memoryLimitInBytes,
+		owned: make(map[*byte]bool),
+	}
+	for _, option := range withOptions {
+		if err := option(p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Size returns the length of each buffer.
+func (p *Pool) Size() int {
+	return p.size
+}
+
+// Get returns a free buffer or allocates a new one.
+func (p *Pool) Get() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if last := len(p.free) - 1; last >= 0 {
+		b := p.free[last]
+		p.free = p.free[:last]
+		return b, nil
+	}
+	if p.limit == 0 || p.inMemory+uint64(p.size) <= p.limit {
+		return p.allocate(), nil
+	}
+	if last := len(p.spilled) - 1; last >= 0 {
+		b := p.spilled[last]
+		p.spilled = p.spilled[:last]
+		return b, nil
+	}
+
+	if p.spill == nil {
+		spill, err := newSpillFile(p.dir)
+		if errors.Is(err, errSpillUnsupported) {
+			return p.allocate(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.spill = spill
+	}
+	buffers, err := p.spill.Grow(p.size, spillGrowth)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range buffers {
+		p.owned[&b[0]] = true
+	}
+	p.spilled = append(p.spilled, buffers[1:]...)
+	return buffers[0], nil
+}
+
+func (p *Pool) allocate() []byte {
+	b := make([]byte, p.size)
+	p.inMemory += uint64(p.size)
+	p.owned[&b[0]] = false
+	return b
+}
+
+// Put returns a buffer to the pool. The buffer may be resliced,
+// as long as it starts at the same byte. Buffers that did not
+// come from the pool are ignored.
+func (p *Pool) Put(b []byte) {
+	if cap(b) < p.size {
+		return
+	}
+	b = b[:p.size]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	spilled, ok := p.owned[&b[0]]
+	switch {
+	case !ok:
+	case spilled:
+		p.spilled = append(p.spilled, b)
+	default:
+		p.free = append(p.free, b)
+	}
+}
+
+// InMemory returns the number of bytes allocated on the heap.
+func (p *Pool) InMemory() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inMemory
+}
+
+// Spilled returns the number of buffers backed by the spill file.
+func (p *Pool) Spilled() (n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, spilled := range p.owned {
+		if spilled {
+			n++
+		}
+	}
+	return n
+}
+
+// Close releases the spill file. Buffers backed by it
+// must not be used afterwards.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.free, p.spilled = nil, nil
+	clear(p.owned)
+	p.inMemory = 0
+	if p.spill == nil {
+		return nil
+	}
+	err := p.spill.Close()
+	p.spill = nil
+	return err
+}
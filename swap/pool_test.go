@@ -0,0 +1,65 @@
+package swap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPoolRecyclesBuffers(t *testing.T) {
+	p, err := NewPool(64, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	b, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(b[:10])
+	again, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &again[0] != &b[0] || len(again) != 64 {
+		t.Fatal("buffer was not recycled")
+	}
+	p.Put(make([]byte, 64)) // foreign buffers are ignored
+	if p.InMemory() != 64 {
+		t.Fatalf("pool holds %d bytes instead of 64", p.InMemory())
+	}
+}
+
+func TestPoolSpillsPastLimit(t *testing.T) {
+	p, err := NewPool(1000, 2500, WithSpillDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	buffers := make([][]byte, 40)
+	for i := range buffers {
+		if buffers[i], err = p.Get(); err != nil {
+			t.Fatal(err)
+		}
+		if len(buffers[i]) != 1000 {
+			t.Fatalf("buffer #%d has %d bytes", i, len(buffers[i]))
+		}
+		copy(buffers[i], bytes.Repeat([]byte{byte(i)}, 1000))
+	}
+	if p.InMemory() > 2500 {
+		t.Fatalf("pool allocated %d bytes past the limit", p.InMemory())
+	}
+	if p.Spilled() < 38 {
+		t.Skip("spill file is not supported on this platform")
+	}
+	for i, b := range buffers {
+		if !bytes.Equal(b, bytes.Repeat([]byte{byte(i)}, 1000)) {
+			t.Fatalf("buffer #%d was overwritten", i)
+		}
+		p.Put(b)
+	}
+	if _, err = p.Get(); err != nil {
+		t.Fatal(err)
+	}
+}
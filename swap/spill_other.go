@@ -0,0 +1,17 @@
+//go:build !unix
+
+package swap
+
+type spillFile struct{}
+
+func newSpillFile(dir string) (*spillFile, error) {
+	return nil, errSpillUnsupported
+}
+
+func (s *spillFile) Grow(size, count int) ([][]byte, error) {
+	return nil, errSpillUnsupported
+}
+
+func (s *spillFile) Close() error {
+	return nil
+}
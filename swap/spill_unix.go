@@ -0,0 +1,63 @@
+//go:build unix
+
+package swap
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// spillFile is a temporary file mapped into memory in regions.
+// The file is unlinked right after creation, so it disappears
+// when closed, even if the process crashes.
+type spillFile struct {
+	f        *os.File
+	size     int64
+	mappings [][]byte
+}
+
+func newSpillFile(dir string) (*spillFile, error) {
+	f, err := os.CreateTemp(dir, "gopar3-swap-*")
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Remove(f.Name()); err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+	return &spillFile{f: f}, nil
+}
+
+// Grow extends the file by a region holding the given number
+// of buffers and maps it. Regions are rounded up to the page size
+// to keep the offset of the next region aligned.
+func (s *spillFile) Grow(size, count int) ([][]byte, error) {
+	page := os.Getpagesize()
+	length := (size*count + page - 1) / page * page
+	if err := s.f.Truncate(s.size + int64(length)); err != nil {
+		return nil, err
+	}
+	mapping, err := syscall.Mmap(
+		int(s.f.Fd()), s.size, length,
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.size += int64(length)
+	s.mappings = append(s.mappings, mapping)
+
+	buffers := make([][]byte, count)
+	for i := range buffers {
+		buffers[i] = mapping[i*size : (i+1)*size : (i+1)*size]
+	}
+	return buffers, nil
+}
+
+func (s *spillFile) Close() (err error) {
+	for _, mapping := range s.mappings {
+		err = errors.Join(err, syscall.Munmap(mapping))
+	}
+	s.mappings = nil
+	return errors.Join(err, s.f.Close())
+}
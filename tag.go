@@ -31,19 +31,55 @@ const (
 	DifferentiatorSize  = TagEndShardQuorum - TagBeginSourceCRC
 )
 
+const (
+	// SourceSizeShardsShift places the number of shards in each batch,
+	// quorum and parity together, in the bits of [Tag.SourceSize]
+	// above the size of the source. Archives that do not record
+	// the number of shards have zeros there.
+	SourceSizeShardsShift = 55
+	SourceSizeShardsMask  = ShardLimit << SourceSizeShardsShift
+
+	// SourceSizeExtended flags [Tag.SourceSize] of tags followed
+	// by [TagExtensionSize] bytes: the [Codec] and the high bytes
+	// of the shard quorum, the shard order, and the number of shards,
+	// so that batches can have more than [ShardLimit] shards.
+	SourceSizeExtended = 1 << 54
+
+	// SourceSizeMask keeps the size of the source, which
+	// is the largest size that can be tagged.
+	SourceSizeMask = SourceSizeExtended - 1
+
+	// TagExtensionCodec and the offsets that follow it locate
+	// the single-byte fields of the extension, which is appended
+	// to the [TagSize] bytes of an extended tag.
+	TagExtensionCodec      = TagSize
+	TagExtensionQuorumHigh = TagExtensionCodec + 1
+	TagExtensionOrderHigh  = TagExtensionQuorumHigh + 1
+	TagExtensionShardsHigh = TagExtensionOrderHigh + 1
+
+	// TagExtensionSize is the number of bytes that extended tags
+	// take beyond [TagSize].
+	TagExtensionSize = TagExtensionShardsHigh + 1 - TagSize
+)
+
 // Tag holds the parameters to perform validated data reconstruction.
 type Tag struct {
 	SourceCRC   uint32
 	SourceSize  uint64
-	ShardQuorum uint8
-	ShardOrder  uint8
+	ShardQuorum uint16
+	ShardOrder  uint16
 	ShardBatch  uint16
+	// Codec and ShardsHigh, the high byte of the number of shards,
+	// are encoded only in the extension of tags flagged
+	// by [SourceSizeExtended].
+	Codec      Codec `json:",omitempty"`
+	ShardsHigh uint8 `json:",omitempty"`
 }
 
 func NewTag(
 	ctx context.Context,
 	r io.Reader,
-	quorum uint8,
+	quorum uint16,
 ) (tag Tag, err error) {
 	tag.ShardQuorum = quorum
 	// 32 * 1024 is io package default
@@ -72,25 +108,77 @@ func NewTag(
 	return
 }
 
+// Size returns the size of the source without the flags
+// and the number of shards recorded in [Tag.SourceSize].
+func (t Tag) Size() uint64 {
+	return t.SourceSize & SourceSizeMask
+}
+
+// Shards returns the number of shards in each batch, quorum and
+// parity together, or zero, if the archive does not record it.
+func (t Tag) Shards() int {
+	return int(t.ShardsHigh)<<8 | int(t.SourceSize&SourceSizeShardsMask>>SourceSizeShardsShift)
+}
+
+// setShards records the number of shards in each batch.
+func (t *Tag) setShards(shards int) {
+	t.SourceSize = t.SourceSize&^SourceSizeShardsMask | uint64(shards&0xff)<<SourceSizeShardsShift
+	t.ShardsHigh = uint8(shards >> 8)
+}
+
+// setCodec records the codec. Tags of codecs other
+// than [CodecReedSolomon] are extended.
+func (t *Tag) setCodec(codec Codec) {
+	t.Codec = codec
+	if codec == CodecReedSolomon {
+		t.SourceSize &^= SourceSizeExtended
+	} else {
+		t.SourceSize |= SourceSizeExtended
+	}
+}
+
+// IsExtended returns true for tags encoded with an extension.
+func (t Tag) IsExtended() bool {
+	return t.SourceSize&SourceSizeExtended != 0
+}
+
+// encodedSize returns the number of bytes that the tag takes.
+func (t Tag) encodedSize() int {
+	if t.IsExtended() {
+		return TagSize + TagExtensionSize
+	}
+	return TagSize
+}
+
+// NewTagFromBytes decodes a tag from the beginning of the bytes.
+// The extension is decoded only if the tag is flagged
+// by [SourceSizeExtended] and the bytes are long enough.
 func NewTagFromBytes(b []byte) Tag {
-	return Tag{
+	t := Tag{
 		SourceCRC: binary.BigEndian.Uint32(
 			b[TagBeginSourceCRC:TagEndSourceCRC],
 		),
 		SourceSize: binary.BigEndian.Uint64(
 			b[TagBeginSourceSize:TagEndSourceSize],
 		),
-		ShardQuorum: b[TagBeginShardQuorum],
-		ShardOrder:  b[TagBeginShardOrder],
+		ShardQuorum: uint16(b[TagBeginShardQuorum]),
+		ShardOrder:  uint16(b[TagBeginShardOrder]),
 		ShardBatch: binary.BigEndian.Uint16(
 			b[TagBeginShardBatch:TagEndShardBatch],
 		),
 	}
+	if t.IsExtended() && len(b) >= TagSize+TagExtensionSize {
+		t.Codec = Codec(b[TagExtensionCodec])
+		t.ShardQuorum |= uint16(b[TagExtensionQuorumHigh]) << 8
+		t.ShardOrder |= uint16(b[TagExtensionOrderHigh]) << 8
+		t.ShardsHigh = b[TagExtensionShardsHigh]
+	}
+	return t
 }
 
 // Bytes encodes the tag into binary format.
 func (t Tag) Bytes() (b []byte) {
-	b = make([]byte, TagSize)
+	b = make([]byte, t.encodedSize())
 	binary.BigEndian.PutUint32(
 		b[TagBeginSourceCRC:TagEndSourceCRC],
 		t.SourceCRC,
@@ -99,12 +187,18 @@ func (t Tag) Bytes() (b []byte) {
 		b[TagBeginSourceSize:TagEndSourceSize],
 		t.SourceSize,
 	)
-	b[TagBeginShardQuorum] = t.ShardQuorum
-	b[TagBeginShardOrder] = t.ShardOrder
+	b[TagBeginShardQuorum] = uint8(t.ShardQuorum)
+	b[TagBeginShardOrder] = uint8(t.ShardOrder)
 	binary.BigEndian.PutUint16(
 		b[TagBeginShardBatch:TagEndShardBatch],
 		t.ShardBatch,
 	)
+	if t.IsExtended() {
+		b[TagExtensionCodec] = uint8(t.Codec)
+		b[TagExtensionQuorumHigh] = uint8(t.ShardQuorum >> 8)
+		b[TagExtensionOrderHigh] = uint8(t.ShardOrder >> 8)
+		b[TagExtensionShardsHigh] = t.ShardsHigh
+	}
 	return b
 }
 
@@ -128,13 +222,13 @@ type Tagger interface {
 type sequentialTagger struct {
 	encoded    []byte
 	tag        Tag
-	shardLimit uint8
+	shardLimit int
 }
 
 // NewSequentialTagger prepares a tagger that increments
 // shard counter to the limit. Then, it sets the shard counter
 // to zero and increments shard batch counter.
-func NewSequentialTagger(t Tag, shardLimit uint8) Tagger {
+func NewSequentialTagger(t Tag, shardLimit int) Tagger {
 	return &sequentialTagger{
 		encoded:    t.Bytes(),
 		tag:        t,
@@ -148,16 +242,16 @@ func (t *sequentialTagger) Bytes() []byte {
 }
 
 func (t *sequentialTagger) Next() error {
-	if t.tag.ShardBatch == math.MaxUint16 && t.tag.ShardOrder == math.MaxUint8 {
+	if t.tag.ShardBatch == math.MaxUint16 && int(t.tag.ShardOrder)+1 >= t.shardLimit {
 		return errors.New("too many shards")
 	}
-	t.tag.ShardOrder++
-	if t.tag.ShardOrder < t.shardLimit {
-		t.encoded[TagBeginShardOrder] = t.tag.ShardOrder
+	if int(t.tag.ShardOrder)+1 < t.shardLimit {
+		t.tag.ShardOrder++
+		t.putOrder()
 	} else {
 		t.tag.ShardOrder = 0
 		t.tag.ShardBatch++
-		t.encoded[TagBeginShardOrder] = 0
+		t.putOrder()
 		binary.BigEndian.PutUint16(
 			t.encoded[TagBeginShardBatch:TagEndShardBatch],
 			t.tag.ShardBatch,
@@ -166,6 +260,13 @@ func (t *sequentialTagger) Next() error {
 	return nil
 }
 
+func (t *sequentialTagger) putOrder() {
+	t.encoded[TagBeginShardOrder] = uint8(t.tag.ShardOrder)
+	if t.tag.IsExtended() {
+		t.encoded[TagExtensionOrderHigh] = uint8(t.tag.ShardOrder >> 8)
+	}
+}
+
 type latteralTagger struct {
 	encoded []byte
 	tag     Tag
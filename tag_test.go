@@ -35,3 +35,54 @@ func TestTagLimits(t *testing.T) {
 		t.Fatal("decoded tags do not match")
 	}
 }
+
+func TestTagShards(t *testing.T) {
+	tag := Tag{SourceSize: SourceSizeMask | SourceSizeSinglePass}
+	if tag.Shards() != 0 {
+		t.Fatal("tag without recorded shards reports", tag.Shards())
+	}
+	tag.setShards(ShardLimit)
+	if tag.Shards() != ShardLimit || tag.Size() != SourceSizeMask || !tag.IsSinglePass() {
+		t.Fatalf("shards %d and size %d do not survive together", tag.Shards(), tag.Size())
+	}
+	if decoded := NewTagFromBytes(tag.Bytes()); decoded.Shards() != ShardLimit {
+		t.Fatal("decoded tag reports", decoded.Shards())
+	}
+}
+
+func TestExtendedTag(t *testing.T) {
+	tag := Tag{SourceCRC: 7, SourceSize: 1 << 40, ShardQuorum: 1000, ShardOrder: 1799, ShardBatch: 3}
+	tag.setShards(1800)
+	tag.setCodec(CodecLeopard)
+	b := tag.Bytes()
+	if len(b) != TagSize+TagExtensionSize {
+		t.Fatalf("extended tag takes %d bytes", len(b))
+	}
+	decoded := NewTagFromBytes(b)
+	if !reflect.DeepEqual(decoded, tag) {
+		t.Fatalf("decoded tag %+v does not match %+v", decoded, tag)
+	}
+	if decoded.Shards() != 1800 || decoded.Size() != 1<<40 {
+		t.Fatalf("decoded tag reports %d shards and size %d", decoded.Shards(), decoded.Size())
+	}
+
+	tag.setCodec(CodecReedSolomon)
+	if len(tag.Bytes()) != TagSize || NewTagFromBytes(tag.Bytes()).Codec != CodecReedSolomon {
+		t.Fatal("standard tag carries an extension")
+	}
+}
+
+func TestSequentialTaggerWideBatches(t *testing.T) {
+	tag := Tag{}
+	tag.setCodec(CodecLeopard)
+	tagger := NewSequentialTagger(tag, 300)
+	for range 300 + 257 {
+		if err := tagger.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := NewTagFromBytes(tagger.Bytes())
+	if next.ShardBatch != 1 || next.ShardOrder != 257 {
+		t.Fatalf("tagged shard %d of batch %d instead of 257 of batch 1", next.ShardOrder, next.ShardBatch)
+	}
+}
@@ -3,16 +3,14 @@ package telomeres
 import (
 	"context"
 	"errors"
-	"io"
 )
 
-// Cursor returns the underlying reader position.
+// Cursor returns the absolute stream position of the decoder.
 // Data chunk boundary can be determined by checking the
 // cursor position before and after calling [Decoder.Stream].
+// Bytes buffered ahead of the position are not counted.
 func (d *Decoder) Cursor() (n int64, err error) {
-	n, err = d.r.Seek(0, io.SeekCurrent)
-	n -= d.telomereTail
-	return
+	return d.in.Position() - d.telomereTail, nil
 }
 
 func (d *Decoder) SeekChunk(ctx context.Context) error {
@@ -23,6 +21,9 @@ func (d *Decoder) SeekChunkBuffer(
 	ctx context.Context,
 	buffer []byte,
 ) (err error) {
+	if d.err != nil {
+		return d.err
+	}
 	var (
 		n int
 		i int
@@ -30,11 +31,13 @@ func (d *Decoder) SeekChunkBuffer(
 	)
 
 	for {
-		n, err = d.r.Read(buffer)
+		n, err = d.in.Read(buffer)
 		for i, c = range buffer[:n] {
-			if c != Mark {
-				_, err = d.r.Seek(-int64(n-i), io.SeekCurrent)
-				return err
+			if c != d.marks.Mark {
+				d.resetSubChunks()
+				d.stuffing = stuffingState{}
+				d.telomereTail = 0
+				return d.in.Unread(n - i)
 			}
 		}
 
@@ -12,14 +12,71 @@ var (
 )
 
 // Decoder reads until a [Mark] byte and strips [Escape] bytes.
+// Sync markers are consumed and their checksums are verified
+// against the decoded data. See [Decoder.SubChunks]. The stream
+// is read through a lookahead buffer, so it does not have
+// to support seeking.
 type Decoder struct {
-	r            io.ReadSeeker
+	in           *lookahead
+	marks        Marks
+	framing      Framing
 	telomereTail int64
+	stuffing     stuffingState
+
+	subChunks  []SubChunk
+	syncOffset int64
+	syncSize   int64
+	syncSum    uint32
+	boundary   bool
+
+	// err is returned by every read, if the decoder
+	// could not be set up. See [NewDecoder].
+	err error
+}
+
+// DecoderOption configures the [Decoder].
+type DecoderOption func(d *Decoder) error
+
+// NewDecoder sets up the decoder. If the reader is an [io.Seeker],
+// [Decoder.Cursor] positions are counted from its current position.
+// Otherwise, they are counted from zero. See [WithStreamOffset].
+// Invalid options fail every read with their error.
+func NewDecoder(r io.Reader, withOptions ...DecoderOption) *Decoder {
+	d := &Decoder{marks: DefaultMarks}
+	var offset int64
+	if seeker, ok := r.(io.Seeker); ok {
+		offset, d.err = seeker.Seek(0, io.SeekCurrent)
+	}
+	d.in = newLookahead(r, offset)
+	for _, option := range withOptions {
+		if d.err == nil {
+			d.err = option(d)
+		}
+	}
+	return d
 }
 
-// NewDecoder sets up the decoder.
-func NewDecoder(r io.ReadSeeker) *Decoder {
-	return &Decoder{r: r}
+// WithStreamOffset sets the stream position of the first byte
+// that the decoder reads. Use it for streams that cannot report
+// their position, like pipes that were partially consumed.
+func WithStreamOffset(offset int64) DecoderOption {
+	return func(d *Decoder) error {
+		if offset < 0 {
+			return errors.New("stream offset cannot be negative")
+		}
+		d.in.offset = offset
+		return nil
+	}
+}
+
+// Framing returns the mode that chunk data was encoded with.
+func (d *Decoder) Framing() Framing {
+	return d.framing
+}
+
+// Marks returns the pair of bytes that frame decoded chunks.
+func (d *Decoder) Marks() Marks {
+	return d.marks
 }
 
 // StreamChunk is a calls [Decoder.StreamChunkBuffer] with default buffer.
@@ -27,7 +84,7 @@ func (d *Decoder) StreamChunk(ctx context.Context, to io.Writer) (written int64,
 	return d.StreamChunkBuffer(ctx, to, d.makeDefaultBuffer())
 }
 
-// StreamChunkBuffer decodes the underlying [io.ReadSeeker] into
+// StreamChunkBuffer decodes the underlying [io.Reader] into
 // a writer using a specified buffer. Context is checked for expiration
 // between writes. [ErrBoundary] ends current chunk. Call again
 // to get the next chunk. Returns io.EOF if there are no more chunks.
@@ -69,17 +126,26 @@ func (d *Decoder) StreamChunkBuffer(ctx context.Context, to io.Writer, buffer []
 // if a [Mark] byte is detected. Return [ErrUnpairedEscape]
 // if an escaped character is not paired with another.
 func (d *Decoder) Read(b []byte) (n int, err error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.framing == FramingStuffing {
+		return d.readStuffed(b)
+	}
 	var (
 		c         byte
 		index     int
 		lastIndex int
 	)
-	n, err = d.r.Read(b)
+	n, err = d.in.Read(b)
 	if n < 1 {
+		if err == io.EOF {
+			d.closeChunk()
+		}
 		return n, err
 	}
 	window := b[:n]
-	if d.telomereTail > 0 && window[0] != Mark {
+	if d.telomereTail > 0 && window[0] != d.marks.Mark {
 		d.telomereTail = 0
 	}
 	// log.Printf("in: %q", b[:n])
@@ -90,52 +156,82 @@ func (d *Decoder) Read(b []byte) (n int, err error) {
 decode:
 	for index, c = range window {
 		switch c {
-		case Mark:
+		case d.marks.Mark:
 			n = n - len(window) + index
 			window = window[index+1:]
 			// log.Printf("window: %q buffer: %q", string(window), b[:n])
 			// log.Printf("int: %q %d %d", b[:n], n, n+len(window)+index)
 			goto drain
-		case Escape:
+		case d.marks.Escape:
 			n--
 			lastIndex = len(window) - 1
 			if index == lastIndex {
-				if err == nil {
-					_, err = d.r.Seek(-1, io.SeekCurrent)
+				// the escaped byte was not read yet
+				if err = d.in.Unread(1); err != nil {
 					return n, err
 				}
+				if pair := d.in.Peek(2); len(pair) == 2 {
+					if n == 0 && len(b) == 1 {
+						// the buffer is too short to read the pair again
+						return d.readEscapedPair(b, pair[1])
+					}
+					d.track(b[:n])
+					return n, nil
+				}
+				d.in.Discard(1)
 				err = ErrUnpairedEscape
 				break decode
 			}
+			if window[index+1] == Sync {
+				n = n + 1 - len(window) + index
+				d.track(b[:n])
+				if err = d.in.Unread(lastIndex - index - 1); err != nil {
+					return n, err
+				}
+				if err = d.readSyncSum(); err != nil || n > 0 {
+					return n, err
+				}
+				return d.Read(b)
+			}
 			copy(window[index:lastIndex], window[index+1:]) // cut current byte
 			window = window[index+1 : lastIndex]
 			// log.Println("escaped window:", string(window), string(b[:n]))
 			goto decode
 		}
 	}
+	d.track(b[:n])
+	if err == io.EOF {
+		d.closeChunk()
+	}
 	return n, err
 
-drain: // discard any remaining [Mark] bytes
+drain:
+	d.track(b[:n])
+	d.closeChunk()
+	return n, d.drain(window)
+}
+
+// drain discards any remaining [Mark] bytes that follow
+// the one that ended the chunk.
+func (d *Decoder) drain(window []byte) error {
 	d.telomereTail++ // for the previous byte that got us to drain
-	for index, c = range window {
-		if c != Mark {
+	for index, c := range window {
+		if c != d.marks.Mark {
 			d.telomereTail += int64(index)
 			// log.Printf("seeking back: %d %q %q", -int64(len(window)-index), string(window), c)
-			// log.Printf("buffer: %q", string(b[n:]))
-			_, err = d.r.Seek(-int64(len(window)-index), io.SeekCurrent)
-			if err != nil {
-				return n, err
+			if err := d.in.Unread(len(window) - index); err != nil {
+				return err
 			}
-			return n, ErrBoundary
+			return ErrBoundary
 		}
 	}
 	d.telomereTail += int64(len(window))
-	return n, ErrBoundary
+	return ErrBoundary
 }
 
 func (d *Decoder) makeDefaultBuffer() []byte {
 	size := 32 * 1024
-	if l, ok := d.r.(io.Reader).(*io.LimitedReader); ok && int64(size) > l.N {
+	if l, ok := d.in.r.(*io.LimitedReader); ok && int64(size) > l.N {
 		if l.N < 1 {
 			size = 1
 		} else {
@@ -144,3 +240,18 @@ func (d *Decoder) makeDefaultBuffer() []byte {
 	}
 	return make([]byte, size)
 }
+
+// readEscapedPair decodes an escape followed by the given byte
+// into a single byte buffer. Sync markers are consumed instead.
+func (d *Decoder) readEscapedPair(b []byte, escaped byte) (int, error) {
+	d.in.Discard(2)
+	if escaped == Sync {
+		if err := d.readSyncSum(); err != nil {
+			return 0, err
+		}
+		return d.Read(b)
+	}
+	b[0] = escaped
+	d.track(b)
+	return 1, nil
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"strings"
 	"testing"
 	"time"
 )
@@ -45,6 +46,37 @@ func TestDecoding(t *testing.T) {
 	}
 }
 
+func TestDecodingOneByteAtATime(t *testing.T) {
+	for _, tc := range encodingTestCases {
+		d := NewDecoder(newTestBuffer([]byte(tc.out)))
+		var (
+			chunks []string
+			chunk  []byte
+			b      = make([]byte, 1)
+		)
+		for range 2 * len(tc.out) { // fails instead of looping forever
+			n, err := d.Read(b)
+			chunk = append(chunk, b[:n]...)
+			if err == nil {
+				continue
+			}
+			if err != ErrBoundary && err != io.EOF {
+				t.Fatal(err)
+			}
+			if len(chunk) > 0 {
+				chunks = append(chunks, string(chunk))
+				chunk = nil
+			}
+			if err == io.EOF {
+				break
+			}
+		}
+		if strings.Join(chunks, "|") != strings.Join(tc.in, "|") {
+			t.Errorf("decoded %q instead of %q", chunks, tc.in)
+		}
+	}
+}
+
 func TestEmptyDecoding(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 	defer cancel()
@@ -2,73 +2,265 @@ package telomeres
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
+	"hash/crc32"
 	"io"
 )
 
-// Encoder appends an [Escape] byte to each [Mark] byte.
+// Encoder appends an [Escape] byte to each [Mark] byte. Encoded
+// bytes are collected in a buffer that is reused between writes,
+// so encoding does not allocate. Long runs of data without marks
+// are written straight from the given slice.
 type Encoder struct {
-	w io.Writer
-	b *bytes.Buffer
-	t []byte
+	w   io.Writer
+	buf []byte
+	t   []byte
+
+	// pair and sum hold bytes passed to buffer,
+	// which would escape to the heap as local variables
+	pair [2]byte
+	sum  [SyncSumSize]byte
+	// read is allocated by the first [Encoder.ReadFrom] call
+	read []byte
+
+	marks        Marks
+	syncInterval int
+	synced       int
+	syncSum      uint32
+
+	framing Framing
+	group   []byte
+	grouped bool
+}
+
+// EncoderBufferSize is the capacity of the buffer that collects
+// encoded bytes before writing them out.
+const EncoderBufferSize = 32 * 1024
+
+// EncoderOption configures the [Encoder].
+type EncoderOption func(e *Encoder) error
+
+// WithSyncInterval makes the encoder insert a sync marker after every
+// given number of chunk bytes. Each marker carries the checksum
+// of the preceding sub-chunk, which lets the [Decoder] report which
+// parts of a damaged chunk are still intact. Zero disables sync markers.
+func WithSyncInterval(bytes int) EncoderOption {
+	return func(e *Encoder) error {
+		if bytes < 0 {
+			return errors.New("sync interval cannot be negative")
+		}
+		e.syncInterval = bytes
+		return nil
+	}
 }
 
 // NewEncoder creates a telomere encoder.
-func NewEncoder(w io.Writer, telomereCount int) (*Encoder, error) {
+func NewEncoder(w io.Writer, telomereCount int, withOptions ...EncoderOption) (*Encoder, error) {
 	if telomereCount < 1 {
 		return nil, errors.New("telomere count must be greater than one")
 	}
 
-	telomeres := make([]byte, telomereCount)
-	for i := range telomeres {
-		telomeres[i] = Mark
+	e := &Encoder{
+		w:     w,
+		buf:   make([]byte, 0, EncoderBufferSize),
+		marks: DefaultMarks,
 	}
+	for _, option := range withOptions {
+		if err := option(e); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.framing == FramingStuffing {
+		if e.syncInterval > 0 {
+			return nil, errStuffingSync
+		}
+		e.group = make([]byte, 0, StuffingGroupLimit)
+	}
+	e.t = bytes.Repeat([]byte{e.marks.Mark}, telomereCount)
+	return e, nil
+}
+
+// Framing returns the mode that chunk data is encoded with.
+func (t *Encoder) Framing() Framing {
+	return t.framing
+}
 
-	return &Encoder{
-		w: w,
-		b: &bytes.Buffer{},
-		t: telomeres,
-	}, nil
+// Marks returns the pair of bytes that frame encoded chunks.
+func (t *Encoder) Marks() Marks {
+	return t.marks
 }
 
+// Write escapes data bytes. If sync interval is set, sync markers
+// are inserted after every interval. See [FramingStuffing]
+// for an alternative to escaping. On error, no bytes are
+// reported as written, even if some of them were.
 func (t *Encoder) Write(b []byte) (n int, err error) {
+	switch {
+	case t.framing == FramingStuffing:
+		err = t.stuff(b)
+	case t.syncInterval > 0:
+		err = t.escapeWithSyncMarkers(b)
+	default:
+		err = t.escape(b)
+	}
+	if err == nil {
+		err = t.flush()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// ReadFrom encodes data from the reader until [io.EOF] as a part of
+// the current chunk. Satisfies [io.ReaderFrom] interface, so that
+// [io.Copy] reuses the encoder buffers.
+func (t *Encoder) ReadFrom(r io.Reader) (n int64, err error) {
+	if t.read == nil {
+		t.read = make([]byte, EncoderBufferSize)
+	}
+	var read int
+	for {
+		read, err = r.Read(t.read)
+		if read > 0 {
+			if _, werr := t.Write(t.read[:read]); werr != nil {
+				return n, werr
+			}
+			n += int64(read)
+		}
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// Cut writes [Mark]s to the underlying Writer to indicate the end
+// of a data chunk. The data written since the last sync marker
+// is closed with one more sync marker.
+func (t *Encoder) Cut() (n int, err error) {
+	if t.grouped {
+		if err = t.flushGroup(); err != nil {
+			return 0, err
+		}
+		t.grouped = false
+	}
+	if t.synced > 0 {
+		if err = t.writeSyncMarker(); err != nil {
+			return 0, err
+		}
+	}
+	n = len(t.buf) + len(t.t)
+	if err = t.buffer(t.t); err != nil {
+		return 0, err
+	}
+	if err = t.flush(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// buffer collects encoded bytes. Bytes that do not fit
+// are written out straight from the slice.
+func (t *Encoder) buffer(p []byte) (err error) {
+	if len(p) > cap(t.buf)-len(t.buf) {
+		if err = t.flush(); err != nil {
+			return err
+		}
+		if len(p) > cap(t.buf) {
+			_, err = t.w.Write(p)
+			return err
+		}
+	}
+	t.buf = append(t.buf, p...)
+	return nil
+}
+
+func (t *Encoder) flush() (err error) {
+	if len(t.buf) > 0 {
+		_, err = t.w.Write(t.buf)
+		t.buf = t.buf[:0]
+	}
+	return err
+}
+
+// escape buffers data with each [Mark] and [Escape] byte preceded
+// by an [Escape]. Both bytes are located using [bytes.IndexByte],
+// which is vectorized on most platforms.
+func (t *Encoder) escape(b []byte) (err error) {
 	var (
-		c      byte
-		window = b
+		mark   = bytes.IndexByte(b, t.marks.Mark)
+		escape = bytes.IndexByte(b, t.marks.Escape)
+		next   int
 	)
-	t.b.Reset()
-
-encode:
-	for n, c = range window {
-		switch c {
-		case Mark, Escape:
-			_, _ = t.b.Write(window[:n])
-			_ = t.b.WriteByte(Escape)
-			_ = t.b.WriteByte(c)
-			window = window[n+1:]
-			goto encode
-		}
-	}
-	_, _ = t.b.Write(window)
-	if n, err = t.w.Write(t.b.Bytes()); err != nil {
-		escaped := false
-		for _, c = range t.b.Bytes()[n:] {
-			if escaped {
-				escaped = false
-				continue
+	for {
+		next = mark
+		if next < 0 || (escape >= 0 && escape < next) {
+			next = escape
+		}
+		if next < 0 {
+			return t.buffer(b)
+		}
+		if len(t.buf)+next+2 <= cap(t.buf) {
+			t.buf = append(t.buf, b[:next]...)
+			t.buf = append(t.buf, t.marks.Escape, b[next])
+		} else {
+			if err = t.buffer(b[:next]); err != nil {
+				return err
 			}
-			if c == Escape {
-				escaped = true
-				n--
+			t.pair[0], t.pair[1] = t.marks.Escape, b[next]
+			if err = t.buffer(t.pair[:]); err != nil {
+				return err
 			}
 		}
-		return n, err
+
+		b = b[next+1:]
+		if mark == next {
+			mark = bytes.IndexByte(b, t.marks.Mark)
+		} else if mark > 0 {
+			mark -= next + 1
+		}
+		if escape == next {
+			escape = bytes.IndexByte(b, t.marks.Escape)
+		} else if escape > 0 {
+			escape -= next + 1
+		}
 	}
-	return len(b), nil
 }
 
-// Cut writes [Mark]s to the underlying Writer to indicate the end
-// of a data chunk.
-func (t *Encoder) Cut() (n int, err error) {
-	return t.w.Write(t.t)
+func (t *Encoder) escapeWithSyncMarkers(b []byte) (err error) {
+	var piece []byte
+	for len(b) > 0 {
+		piece = b[:min(len(b), t.syncInterval-t.synced)]
+		if err = t.escape(piece); err != nil {
+			return err
+		}
+		t.syncSum = crc32.Update(t.syncSum, syncTable, piece)
+		t.synced += len(piece)
+		b = b[len(piece):]
+		if t.synced == t.syncInterval {
+			if err = t.writeSyncMarker(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Encoder) writeSyncMarker() (err error) {
+	t.pair[0], t.pair[1] = t.marks.Escape, Sync
+	if err = t.buffer(t.pair[:]); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(t.sum[:], t.syncSum)
+	if err = t.escape(t.sum[:]); err != nil {
+		return err
+	}
+	t.synced = 0
+	t.syncSum = 0
+	return nil
 }
@@ -3,6 +3,7 @@ package telomeres
 import (
 	"bytes"
 	"io"
+	"math/rand"
 	"strings"
 	"testing"
 )
@@ -71,3 +72,99 @@ func TestEmptyEncoding(t *testing.T) {
 		t.Fatalf("encoded %d bytes, but should have been zero", n)
 	}
 }
+
+func TestEncoderDoesNotAllocate(t *testing.T) {
+	data := randomData(64 * 1024)
+	for name, options := range map[string][]EncoderOption{
+		"escape":   nil,
+		"sync":     {WithSyncInterval(1024)},
+		"stuffing": {WithEncoderFraming(FramingStuffing)},
+	} {
+		e, err := NewEncoder(io.Discard, 4, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		allocations := testing.AllocsPerRun(10, func() {
+			if _, err = e.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = e.Cut(); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocations > 0 {
+			t.Errorf("%s encoder made %.1f allocations per chunk", name, allocations)
+		}
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	const size = 1 << 20
+	payloads := map[string][]byte{
+		"unmarked": bytes.Repeat([]byte("abcdefghijklmnopqrstuvwxyz"), size/26),
+		"random":   make([]byte, size),
+		"marks":    bytes.Repeat([]byte{Mark}, size),
+	}
+	_, _ = rand.Read(payloads["random"])
+
+	for name, options := range map[string][]EncoderOption{
+		"escape":   nil,
+		"sync":     {WithSyncInterval(4096)},
+		"stuffing": {WithEncoderFraming(FramingStuffing)},
+	} {
+		for payload, data := range payloads {
+			b.Run(name+"/"+payload, func(b *testing.B) {
+				e, err := NewEncoder(io.Discard, 4, options...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(data)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err = e.Write(data); err != nil {
+						b.Fatal(err)
+					}
+					if _, err = e.Cut(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkEncoderReadFrom(b *testing.B) {
+	data := make([]byte, 1<<20)
+	_, _ = rand.Read(data)
+	r := bytes.NewReader(data)
+	e, err := NewEncoder(io.Discard, 4)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(data)
+		if _, err = io.Copy(e, r); err != nil {
+			b.Fatal(err)
+		}
+		if _, err = e.Cut(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMemoryCopy is the baseline for encoder throughput.
+func BenchmarkMemoryCopy(b *testing.B) {
+	data := make([]byte, 1<<20)
+	buffer := make([]byte, EncoderBufferSize)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for offset := 0; offset < len(data); offset += len(buffer) {
+			copy(buffer, data[offset:])
+		}
+	}
+}
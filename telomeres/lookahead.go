@@ -0,0 +1,101 @@
+package telomeres
+
+import (
+	"errors"
+	"io"
+)
+
+// LookaheadSize is the capacity of the buffer that lets
+// the [Decoder] step back over bytes it has read too far.
+const LookaheadSize = 32 * 1024
+
+var errUnreadTooFar = errors.New("cannot step back past the lookahead buffer")
+
+// lookahead buffers a plain [io.Reader], so that the [Decoder]
+// can return bytes it read past a chunk boundary without
+// seeking, and tracks the absolute position in the stream.
+type lookahead struct {
+	r     io.Reader
+	buf   []byte
+	start int
+	end   int
+	// offset is the stream position of buf[0]
+	offset int64
+	err    error
+}
+
+func newLookahead(r io.Reader, offset int64) *lookahead {
+	return &lookahead{
+		r:      r,
+		buf:    make([]byte, LookaheadSize),
+		offset: offset,
+	}
+}
+
+// Position returns the stream position of the next unread byte.
+func (l *lookahead) Position() int64 {
+	return l.offset + int64(l.start)
+}
+
+// fill reads from the underlying reader until at least
+// the given number of bytes are buffered or an error occurs.
+// Bytes already consumed are discarded to make room.
+func (l *lookahead) fill(size int) {
+	if size > len(l.buf) {
+		size = len(l.buf)
+	}
+	if l.start+size > len(l.buf) {
+		l.offset += int64(l.start)
+		l.end = copy(l.buf, l.buf[l.start:l.end])
+		l.start = 0
+	}
+	var n int
+	for l.end-l.start < size && l.err == nil {
+		n, l.err = l.r.Read(l.buf[l.end:])
+		l.end += n
+	}
+}
+
+// Read copies buffered bytes, reading more only when the
+// buffer is empty. All bytes returned by one call can be
+// stepped back over with [lookahead.Unread].
+func (l *lookahead) Read(b []byte) (n int, err error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if l.start == l.end {
+		l.offset += int64(l.end)
+		l.start, l.end = 0, 0
+		l.fill(1)
+		if l.start == l.end {
+			err, l.err = l.err, nil
+			return 0, err
+		}
+	}
+	n = copy(b, l.buf[l.start:l.end])
+	l.start += n
+	return n, nil
+}
+
+// Unread steps back over the last n bytes.
+func (l *lookahead) Unread(n int) error {
+	if n > l.start {
+		return errUnreadTooFar
+	}
+	l.start -= n
+	return nil
+}
+
+// Peek returns up to n next bytes without consuming them.
+// Fewer bytes are returned only at the end of the stream.
+func (l *lookahead) Peek(n int) []byte {
+	if l.end-l.start < n {
+		l.fill(n)
+	}
+	return l.buf[l.start:min(l.end, l.start+n)]
+}
+
+// Discard consumes n bytes returned by [lookahead.Peek].
+func (l *lookahead) Discard(n int) {
+	l.start += n
+}
@@ -0,0 +1,69 @@
+package telomeres
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestDecodingNonSeekableStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	chunks := [][]byte{
+		randomData(100),
+		[]byte(`\`),
+		randomData(LookaheadSize + 100),
+		randomData(7),
+	}
+	encoded := encodeWithSyncMarkers(t, 9, chunks...)
+	const offset = 1000
+
+	for name, r := range map[string]func() io.Reader{
+		"pipe": func() io.Reader {
+			pr, pw := io.Pipe()
+			go func() {
+				_, err := pw.Write(encoded)
+				pw.CloseWithError(err)
+			}()
+			return pr
+		},
+		"one byte": func() io.Reader {
+			return iotest.OneByteReader(bytes.NewReader(encoded))
+		},
+		"half": func() io.Reader {
+			return iotest.HalfReader(bytes.NewReader(encoded))
+		},
+	} {
+		d := NewDecoder(r(), WithStreamOffset(offset))
+		b := &bytes.Buffer{}
+		for i, chunk := range chunks {
+			if err := d.SeekChunk(ctx); err != nil {
+				t.Fatal(err)
+			}
+			first, _ := d.Cursor()
+			b.Reset()
+			if _, err := d.StreamChunkBuffer(ctx, b, make([]byte, 5)); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b.Bytes(), chunk) {
+				t.Fatalf("%s: decoded chunk %d does not match", name, i)
+			}
+			last, _ := d.Cursor()
+			if encoded[first-offset-1] != Mark || encoded[first-offset] == Mark || encoded[last-offset] != Mark {
+				t.Fatalf("%s: cursor positions %d:%d of chunk %d include telomeres", name, first, last, i)
+			}
+			for _, sub := range d.SubChunks() {
+				if !sub.Intact {
+					t.Fatalf("%s: sub-chunk %+v of chunk %d is damaged", name, sub, i)
+				}
+			}
+		}
+		if _, err := d.StreamChunk(ctx, b); err != io.EOF {
+			t.Fatalf("%s: expected end of stream, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package telomeres
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoTelomeres indicates that no telomere sequences were detected.
+var ErrNoTelomeres = errors.New("no telomere sequences detected")
+
+// Marks is a pair of bytes that frame chunks. Payloads heavy
+// in the default [Mark] and [Escape] bytes, like text logs,
+// encode smaller with a pair of rarely used bytes.
+type Marks struct {
+	Mark   byte
+	Escape byte
+}
+
+// DefaultMarks are used unless other marks are configured.
+var DefaultMarks = Marks{Mark: Mark, Escape: Escape}
+
+// Validate returns an error if the pair cannot frame chunks
+// unambiguously.
+func (m Marks) Validate() error {
+	if m.Mark == m.Escape {
+		return errors.New("telomere mark and escape bytes must differ")
+	}
+	if m.Mark == Sync || m.Escape == Sync {
+		return fmt.Errorf("telomere mark and escape bytes cannot be the sync byte %q", Sync)
+	}
+	return nil
+}
+
+func (m Marks) String() string {
+	return fmt.Sprintf("%q%q", m.Mark, m.Escape)
+}
+
+// WithEncoderMarks sets the bytes that frame encoded chunks.
+func WithEncoderMarks(m Marks) EncoderOption {
+	return func(e *Encoder) error {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		e.marks = m
+		return nil
+	}
+}
+
+// WithDecoderMarks sets the bytes that frame decoded chunks.
+// See [DetectMarks] for chunks framed by unknown marks.
+func WithDecoderMarks(m Marks) DecoderOption {
+	return func(d *Decoder) error {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		d.marks = m
+		return nil
+	}
+}
+
+// detectionRunLength is the shortest run of repeated bytes
+// considered to be a telomere during detection.
+const detectionRunLength = 4
+
+// DetectMarks finds the pair of marks used to encode the stream
+// by frequency analysis of long runs of repeated bytes. Outside
+// of telomeres, the true [Marks.Mark] only appears alone, right
+// after its [Marks.Escape] byte. Limit the reader to sample
+// a portion of a large stream.
+func DetectMarks(r io.Reader) (m Marks, err error) {
+	var (
+		runs     [256]int
+		short    [256]int
+		isolated [256][256]int
+		buffer   = make([]byte, 32*1024)
+		n        int
+		current  byte
+		before   byte
+		length   int
+	)
+	closeRun := func() {
+		switch {
+		case length >= detectionRunLength:
+			runs[current]++
+		case length == 1:
+			isolated[current][before]++
+		case length > 1:
+			short[current]++
+		}
+	}
+
+	for err == nil {
+		n, err = r.Read(buffer)
+		for _, c := range buffer[:n] {
+			if c == current && length > 0 {
+				length++
+				continue
+			}
+			if length > 0 {
+				closeRun()
+				before = current
+			}
+			current = c
+			length = 1
+		}
+	}
+	if err != io.EOF {
+		return m, err
+	}
+	if length > 0 {
+		closeRun()
+	}
+
+	best := 0
+	for mark, count := range runs {
+		if count <= best || short[mark]*10 > count {
+			continue
+		}
+		total, escape := 0, 0
+		for preceding, occurrences := range isolated[mark] {
+			total += occurrences
+			if occurrences > isolated[mark][escape] {
+				escape = preceding
+			}
+		}
+		if total == 0 {
+			// chunks did not contain the mark byte,
+			// so the escape cannot be determined
+			escape = Escape
+		} else if isolated[mark][escape]*10 < total*9 {
+			continue // too many unescaped occurrences
+		}
+		candidate := Marks{Mark: byte(mark), Escape: byte(escape)}
+		if candidate.Validate() != nil {
+			continue
+		}
+		m, best = candidate, count
+	}
+	if best == 0 {
+		return DefaultMarks, ErrNoTelomeres
+	}
+	return m, nil
+}
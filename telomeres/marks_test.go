@@ -0,0 +1,97 @@
+package telomeres
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCustomMarks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	marks := Marks{Mark: '#', Escape: '~'}
+	chunks := []string{
+		`{"level":"info","message":"C:\\Windows"}`,
+		"#hash ~tilde",
+		strings.Repeat("#", 10),
+	}
+	b := &bytes.Buffer{}
+	e, err := NewEncoder(b, 4, WithEncoderMarks(marks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = e.Cut(); err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range chunks {
+		if _, err = e.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = e.Cut(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.HasPrefix(b.String(), "####"+chunks[0]+"####") {
+		t.Log("encoded:", b.String())
+		t.Fatal("default marks were escaped")
+	}
+
+	detected, err := DetectMarks(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detected != marks {
+		t.Fatalf("detected marks %s instead of %s", detected, marks)
+	}
+
+	d := NewDecoder(newTestBuffer(b.Bytes()), WithDecoderMarks(detected))
+	decoded := &bytes.Buffer{}
+	for _, chunk := range chunks {
+		decoded.Reset()
+		if _, err = d.StreamChunk(ctx, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.String() != chunk {
+			t.Log("expecting:", chunk)
+			t.Log("    given:", decoded.String())
+			t.Fatal("decoder output does not match expectation")
+		}
+	}
+}
+
+func TestDetectDefaultMarks(t *testing.T) {
+	for _, tc := range encodingTestCases {
+		detected, err := DetectMarks(strings.NewReader(tc.out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detected != DefaultMarks {
+			t.Fatalf("detected marks %s instead of %s in %q", detected, DefaultMarks, tc.out)
+		}
+	}
+
+	_, err := DetectMarks(strings.NewReader("no telomeres here"))
+	if !errors.Is(err, ErrNoTelomeres) {
+		t.Fatal("expected no telomeres, but got:", err)
+	}
+}
+
+func TestInvalidMarks(t *testing.T) {
+	for _, marks := range [...]Marks{
+		{Mark: '#', Escape: '#'},
+		{Mark: Sync, Escape: '~'},
+		{Mark: '#', Escape: Sync},
+	} {
+		if _, err := NewEncoder(&bytes.Buffer{}, 4, WithEncoderMarks(marks)); err == nil {
+			t.Fatalf("encoder accepted invalid marks %s", marks)
+		}
+		d := NewDecoder(&testBuffer{}, WithDecoderMarks(marks))
+		if _, err := d.Read(make([]byte, 1)); err == nil || err.Error() != marks.Validate().Error() {
+			t.Fatalf("decoder accepted invalid marks %s", marks)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package telomeres
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Framing selects how chunk data is kept free of [Marks.Mark] bytes.
+type Framing uint8
+
+const (
+	// FramingEscape precedes each [Marks.Mark] and [Marks.Escape]
+	// data byte with an [Marks.Escape] byte. Overhead depends
+	// on the data and reaches 100% when data consists of marks.
+	FramingEscape Framing = iota
+
+	// FramingStuffing uses Consistent Overhead Byte Stuffing with
+	// [Marks.Mark] in place of the zero byte. Data is split into
+	// groups, each preceded by a code byte that holds the group
+	// length. Overhead never exceeds one byte for every
+	// [StuffingGroupLimit] bytes plus one byte for every chunk.
+	// Sync markers are not supported.
+	FramingStuffing
+)
+
+// StuffingGroupLimit is the longest group of data bytes
+// that follows one code byte in [FramingStuffing].
+const StuffingGroupLimit = 254
+
+func (f Framing) String() string {
+	switch f {
+	case FramingEscape:
+		return "escape"
+	case FramingStuffing:
+		return "stuffing"
+	default:
+		return fmt.Sprintf("Framing(%d)", uint8(f))
+	}
+}
+
+// MarshalText encodes framing mode name.
+func (f Framing) MarshalText() ([]byte, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText decodes framing mode name.
+func (f *Framing) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "escape":
+		*f = FramingEscape
+	case "stuffing":
+		*f = FramingStuffing
+	default:
+		return fmt.Errorf("unknown telomere framing %q", b)
+	}
+	return nil
+}
+
+// Validate returns an error for unknown framing modes.
+func (f Framing) Validate() error {
+	switch f {
+	case FramingEscape, FramingStuffing:
+		return nil
+	default:
+		return fmt.Errorf("unknown telomere framing %d", uint8(f))
+	}
+}
+
+// WithEncoderFraming selects how the encoder keeps chunk data free of marks.
+func WithEncoderFraming(f Framing) EncoderOption {
+	return func(e *Encoder) error {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+		e.framing = f
+		return nil
+	}
+}
+
+// WithDecoderFraming selects the framing mode the chunks were encoded with.
+func WithDecoderFraming(f Framing) DecoderOption {
+	return func(d *Decoder) error {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+		d.framing = f
+		return nil
+	}
+}
+
+var errStuffingSync = errors.New("sync markers cannot be used with stuffing framing")
+
+// stuff splits data into groups at each [Marks.Mark] byte.
+// The last unfinished group is kept until the next write or [Encoder.Cut].
+func (t *Encoder) stuff(b []byte) (err error) {
+	var (
+		mark    int
+		segment []byte
+		take    int
+	)
+	if len(b) > 0 {
+		t.grouped = true
+	}
+	for {
+		mark = bytes.IndexByte(b, t.marks.Mark)
+		segment = b
+		if mark >= 0 {
+			segment = b[:mark]
+		}
+		for len(segment) > 0 {
+			take = min(len(segment), StuffingGroupLimit-len(t.group))
+			t.group = append(t.group, segment[:take]...)
+			segment = segment[take:]
+			if len(t.group) == StuffingGroupLimit {
+				if err = t.flushGroup(); err != nil {
+					return err
+				}
+			}
+		}
+		if mark < 0 {
+			return nil
+		}
+		if err = t.flushGroup(); err != nil {
+			return err
+		}
+		b = b[mark+1:]
+	}
+}
+
+// flushGroup buffers the code byte and data of the current group.
+func (t *Encoder) flushGroup() (err error) {
+	if cap(t.buf)-len(t.buf) <= len(t.group) {
+		if err = t.flush(); err != nil {
+			return err
+		}
+	}
+	t.buf = append(t.buf, byte(len(t.group)+1)^t.marks.Mark)
+	t.buf = append(t.buf, t.group...)
+	t.group = t.group[:0]
+	return nil
+}
+
+// stuffingState tracks a group between [Decoder.Read] calls.
+type stuffingState struct {
+	remaining int
+	// implicit is true when the group is followed by a mark
+	implicit bool
+	// pending is true when a decoded mark waits for the next
+	// code byte, because the last group is not followed by one
+	pending bool
+}
+
+// readStuffed decodes [FramingStuffing] in place. Each input byte
+// produces at most one output byte, so the output never overtakes
+// the input.
+func (d *Decoder) readStuffed(b []byte) (n int, err error) {
+	read, err := d.in.Read(b)
+	if read < 1 {
+		if err == io.EOF {
+			d.closeChunk()
+		}
+		return 0, err
+	}
+	window := b[:read]
+	if d.telomereTail > 0 && window[0] != d.marks.Mark {
+		d.telomereTail = 0
+	}
+
+	s := &d.stuffing
+	for index, c := range window {
+		if c == d.marks.Mark {
+			// final group of a chunk is not followed by a mark
+			*s = stuffingState{}
+			d.track(b[:n])
+			d.closeChunk()
+			return n, d.drain(window[index+1:])
+		}
+		if s.remaining > 0 {
+			b[n] = c
+			n++
+			s.remaining--
+			if s.remaining == 0 {
+				s.pending = s.implicit
+			}
+			continue
+		}
+
+		// code byte begins the next group
+		if s.pending {
+			b[n] = d.marks.Mark
+			n++
+		}
+		code := int(c ^ d.marks.Mark)
+		s.remaining = code - 1
+		s.implicit = code <= StuffingGroupLimit
+		s.pending = s.remaining == 0 && s.implicit
+	}
+
+	d.track(b[:n])
+	if err == io.EOF {
+		d.closeChunk()
+	}
+	return n, err
+}
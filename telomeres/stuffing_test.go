@@ -0,0 +1,87 @@
+package telomeres
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStuffingEncodingDecoding(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	chunks := [][]byte{
+		[]byte("hello"),
+		[]byte(":"),
+		[]byte("::::::::"),
+		bytes.Repeat([]byte("!"), StuffingGroupLimit-1),
+		bytes.Repeat([]byte("!"), StuffingGroupLimit),
+		bytes.Repeat([]byte("!"), StuffingGroupLimit+1),
+		append(bytes.Repeat([]byte("!"), StuffingGroupLimit), ':'),
+		bytes.Repeat([]byte("!"), StuffingGroupLimit*3),
+		randomData(1000),
+	}
+	for _, marks := range [...]Marks{DefaultMarks, {Mark: 0, Escape: 1}} {
+		b := &bytes.Buffer{}
+		e, err := NewEncoder(b, 4,
+			WithEncoderMarks(marks),
+			WithEncoderFraming(FramingStuffing),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = e.Cut(); err != nil {
+			t.Fatal(err)
+		}
+		for _, chunk := range chunks {
+			before := b.Len()
+			// split writes to carry groups between calls
+			if _, err = e.Write(chunk[:len(chunk)/2]); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = e.Write(chunk[len(chunk)/2:]); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = e.Cut(); err != nil {
+				t.Fatal(err)
+			}
+			encoded := b.Bytes()[before : b.Len()-4]
+			if bytes.IndexByte(encoded, marks.Mark) != -1 {
+				t.Fatalf("encoded chunk %q contains a mark", encoded)
+			}
+			if limit := len(chunk) + len(chunk)/StuffingGroupLimit + 1; len(encoded) > limit {
+				t.Fatalf("encoded %d bytes into %d, which is more than %d", len(chunk), len(encoded), limit)
+			}
+		}
+
+		for _, bufferSize := range [...]int{2, 3, 7, 32 * 1024} {
+			d := NewDecoder(newTestBuffer(b.Bytes()),
+				WithDecoderMarks(marks),
+				WithDecoderFraming(FramingStuffing),
+			)
+			decoded := &bytes.Buffer{}
+			for _, chunk := range chunks {
+				decoded.Reset()
+				if _, err = d.StreamChunkBuffer(ctx, decoded, make([]byte, bufferSize)); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(decoded.Bytes(), chunk) {
+					t.Logf("expected: %q", chunk)
+					t.Logf(" decoded: %q", decoded.Bytes())
+					t.Fatalf("decoded chunk does not match using %d byte buffer", bufferSize)
+				}
+			}
+		}
+	}
+}
+
+func TestStuffingRejectsSyncMarkers(t *testing.T) {
+	_, err := NewEncoder(&bytes.Buffer{}, 4,
+		WithSyncInterval(64),
+		WithEncoderFraming(FramingStuffing),
+	)
+	if err == nil {
+		t.Fatal("stuffing framing accepted sync markers")
+	}
+}
@@ -0,0 +1,111 @@
+package telomeres
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"slices"
+)
+
+// SyncSumSize is the number of checksum bytes that follow
+// each sync marker before escaping.
+const SyncSumSize = 4
+
+var syncTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SubChunk is a span of decoded chunk data guarded by a sync marker.
+type SubChunk struct {
+	// Offset is the position of the first decoded byte
+	// relative to the beginning of the chunk.
+	Offset int64
+	Size   int64
+	// Intact is true when the sub-chunk data matches
+	// the checksum recorded after its sync marker.
+	Intact bool
+}
+
+// SubChunks returns the sub-chunks of the last decoded chunk.
+// Chunks written without sync markers return `nil`. The tail
+// of a chunk that lost its final sync marker is reported
+// as a damaged sub-chunk.
+func (d *Decoder) SubChunks() []SubChunk {
+	if len(d.subChunks) == 0 {
+		return nil
+	}
+	return slices.Clone(d.subChunks)
+}
+
+// track accounts decoded bytes against the pending sub-chunk.
+// The first bytes after a chunk boundary begin a new chunk.
+func (d *Decoder) track(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	if d.boundary {
+		d.resetSubChunks()
+	}
+	d.syncSum = crc32.Update(d.syncSum, syncTable, b)
+	d.syncSize += int64(len(b))
+}
+
+// closeChunk records the data after the last sync marker
+// as damaged, because an intact chunk always ends with one.
+func (d *Decoder) closeChunk() {
+	if len(d.subChunks) > 0 && d.syncSize > 0 && !d.boundary {
+		d.subChunks = append(d.subChunks, SubChunk{
+			Offset: d.syncOffset,
+			Size:   d.syncSize,
+		})
+	}
+	d.boundary = true
+}
+
+func (d *Decoder) resetSubChunks() {
+	d.subChunks = d.subChunks[:0]
+	d.syncOffset = 0
+	d.syncSize = 0
+	d.syncSum = 0
+	d.boundary = false
+}
+
+// readSyncSum decodes the checksum following a sync marker
+// and records the sub-chunk that it guards. A [Mark] or the end
+// of the stream inside the checksum marks the sub-chunk as damaged.
+func (d *Decoder) readSyncSum() error {
+	var (
+		raw   = d.in.Peek(2 * SyncSumSize)
+		sum   [SyncSumSize]byte
+		n     = len(raw)
+		index int
+		found int
+	)
+
+decode:
+	for ; index < n && found < SyncSumSize; index++ {
+		switch raw[index] {
+		case d.marks.Mark:
+			break decode
+		case d.marks.Escape:
+			if index+1 == n {
+				break decode
+			}
+			index++
+		}
+		sum[found] = raw[index]
+		found++
+	}
+	d.in.Discard(index)
+
+	if d.boundary {
+		d.resetSubChunks()
+	}
+	d.subChunks = append(d.subChunks, SubChunk{
+		Offset: d.syncOffset,
+		Size:   d.syncSize,
+		Intact: found == SyncSumSize &&
+			binary.BigEndian.Uint32(sum[:]) == d.syncSum,
+	})
+	d.syncOffset += d.syncSize
+	d.syncSize = 0
+	d.syncSum = 0
+	return nil
+}
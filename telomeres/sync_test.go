@@ -0,0 +1,108 @@
+package telomeres
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func encodeWithSyncMarkers(t *testing.T, interval int, chunks ...[]byte) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	e, err := NewEncoder(b, 4, WithSyncInterval(interval))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = e.Cut(); err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range chunks {
+		n, err := e.Write(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(chunk) {
+			t.Fatalf("wrote %d chunk bytes, but expecting %d", n, len(chunk))
+		}
+		if _, err = e.Cut(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.Bytes()
+}
+
+func TestSyncMarkers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	chunks := [][]byte{randomData(100), randomData(7), randomData(33)}
+	decoder := NewDecoder(newTestBuffer(
+		encodeWithSyncMarkers(t, 7, chunks...)))
+
+	b := &bytes.Buffer{}
+	for _, chunk := range chunks {
+		b.Reset()
+		if _, err := decoder.StreamChunk(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b.Bytes(), chunk) {
+			t.Logf("expected: %q", chunk)
+			t.Logf(" decoded: %q", b.Bytes())
+			t.Fatal("decoded chunk does not match")
+		}
+
+		subChunks := decoder.SubChunks()
+		if expected := (len(chunk) + 6) / 7; len(subChunks) != expected {
+			t.Fatalf("found %d sub-chunks instead of %d", len(subChunks), expected)
+		}
+		var offset int64
+		for _, subChunk := range subChunks {
+			if !subChunk.Intact {
+				t.Fatalf("sub-chunk %+v is damaged", subChunk)
+			}
+			if subChunk.Offset != offset {
+				t.Fatalf("sub-chunk offset %d does not match %d", subChunk.Offset, offset)
+			}
+			offset += subChunk.Size
+		}
+		if offset != int64(len(chunk)) {
+			t.Fatalf("sub-chunks cover %d bytes instead of %d", offset, len(chunk))
+		}
+	}
+}
+
+func TestSyncMarkersLocateDamage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	chunk := bytes.Repeat([]byte("abcdefghij"), 5)
+	encoded := encodeWithSyncMarkers(t, 10, chunk)
+	// delete one byte from the third sub-chunk,
+	// skipping the leading telomere, two sub-chunks
+	// and their sync markers
+	at := 4 + 2*(10+2+SyncSumSize) + 3
+	damaged := append(encoded[:at:at], encoded[at+1:]...)
+
+	decoder := NewDecoder(newTestBuffer(damaged))
+	b := &bytes.Buffer{}
+	if _, err := decoder.StreamChunk(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != len(chunk)-1 {
+		t.Fatalf("decoded %d bytes instead of %d", b.Len(), len(chunk)-1)
+	}
+
+	subChunks := decoder.SubChunks()
+	if len(subChunks) != 5 {
+		t.Fatalf("found %d sub-chunks instead of %d", len(subChunks), 5)
+	}
+	for i, subChunk := range subChunks {
+		if subChunk.Intact == (i == 2) {
+			t.Fatalf("sub-chunk #%d reported as intact=%t", i, subChunk.Intact)
+		}
+	}
+	if subChunks[2].Size != 9 {
+		t.Fatalf("damaged sub-chunk size is %d instead of %d", subChunks[2].Size, 9)
+	}
+}
@@ -21,4 +21,10 @@ const (
 	// Escape indicates that the next byte should
 	// be treated as raw data.
 	Escape = '\\'
+
+	// Sync follows an [Escape] byte to form a sync marker
+	// inside a data chunk. The marker is followed by
+	// the escaped Castagnoli sum of the data written since
+	// the previous marker.
+	Sync = '|'
 )
@@ -10,7 +10,7 @@ import (
 	"time"
 )
 
-func ExampleEncoder_Encode() {
+func ExampleEncoder() {
 	b := &bytes.Buffer{}
 	e, _ := NewEncoder(b, 4)
 
@@ -24,7 +24,7 @@ func ExampleEncoder_Encode() {
 	// Output: ::::hello::::world::::
 }
 
-func ExampleDecoder_Decode() {
+func ExampleDecoder() {
 	d := NewDecoder(
 		newTestBuffer([]byte("::::hello::::world::::")),
 	)
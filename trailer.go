@@ -0,0 +1,137 @@
+package gopar3
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+)
+
+const (
+	// SourceSizeSinglePass flags [Tag.SourceSize] of sources inflated
+	// in a single pass. Their [Tag.SourceCRC] is a random identifier,
+	// because the checksum is only known after all the shards
+	// are written. The checksum follows in a [Trailer].
+	SourceSizeSinglePass = 1 << 63
+
+	// TrailerShardOrder and TrailerShardBatch tag the shards
+	// that carry a [Trailer] instead of data. No batch of up to
+	// [ShardLimit] shards has a shard of that order.
+	TrailerShardOrder = math.MaxUint8
+	TrailerShardBatch = math.MaxUint16
+
+	// WideTrailerShardOrder replaces [TrailerShardOrder] in extended
+	// tags, because wide batches of up to [WideShardLimit] shards
+	// reach order [TrailerShardOrder] with data.
+	WideTrailerShardOrder = math.MaxUint16
+
+	TrailerSize = TagBytesForCRC + TagBytesForSourceSize
+)
+
+// Trailer records the checksum of a source inflated in a single pass.
+// It is written in place of shard data after the last batch.
+type Trailer struct {
+	SourceCRC  uint32
+	SourceSize uint64
+}
+
+// NewTrailerFromBytes decodes a trailer from shard data.
+func NewTrailerFromBytes(b []byte) (Trailer, error) {
+	if len(b) != TrailerSize {
+		return Trailer{}, errors.New("trailer size does not match")
+	}
+	return Trailer{
+		SourceCRC:  binary.BigEndian.Uint32(b[:TagBytesForCRC]),
+		SourceSize: binary.BigEndian.Uint64(b[TagBytesForCRC:]),
+	}, nil
+}
+
+// Bytes encodes the trailer into binary format.
+func (t Trailer) Bytes() []byte {
+	b := make([]byte, TrailerSize)
+	binary.BigEndian.PutUint32(b[:TagBytesForCRC], t.SourceCRC)
+	binary.BigEndian.PutUint64(b[TagBytesForCRC:], t.SourceSize)
+	return b
+}
+
+// IsSinglePass returns true if the source checksum is
+// recorded in a [Trailer] rather than the tag.
+func (t Tag) IsSinglePass() bool {
+	return t.SourceSize&SourceSizeSinglePass != 0
+}
+
+// IsTrailer returns true for tags of shards carrying a [Trailer].
+func (t Tag) IsTrailer() bool {
+	return t.ShardOrder == t.trailerShardOrder() && t.ShardBatch == TrailerShardBatch
+}
+
+func (t Tag) trailerShardOrder() uint16 {
+	if t.IsExtended() {
+		return WideTrailerShardOrder
+	}
+	return TrailerShardOrder
+}
+
+// newSinglePassTag identifies a source by a random number
+// in place of the checksum, which is not known yet.
+func newSinglePassTag(size int64, quorum uint16) (tag Tag, err error) {
+	var id [TagBytesForCRC]byte
+	if _, err = rand.Read(id[:]); err != nil {
+		return tag, err
+	}
+	return Tag{
+		SourceCRC:   binary.BigEndian.Uint32(id[:]),
+		SourceSize:  uint64(size) | SourceSizeSinglePass,
+		ShardQuorum: quorum,
+	}, nil
+}
+
+// trailerTagger tags every shard as a trailer.
+type trailerTagger struct {
+	encoded []byte
+}
+
+func newTrailerTagger(t Tag) Tagger {
+	t.ShardOrder = t.trailerShardOrder()
+	t.ShardBatch = TrailerShardBatch
+	return &trailerTagger{encoded: t.Bytes()}
+}
+
+func (t *trailerTagger) Bytes() []byte {
+	return t.encoded
+}
+
+func (t *trailerTagger) Next() error {
+	return nil
+}
+
+// adoptTrailers removes trailer shards from the [Index] and attaches
+// them to the files inflated in a single pass.
+func (i Index) adoptTrailers() {
+	trailers := make(map[string]Trailer)
+	for differentiator, f := range i.Files {
+		kept := f.Shards[:0]
+		for _, shard := range f.Shards {
+			if !shard.Tag.IsTrailer() {
+				kept = append(kept, shard)
+				continue
+			}
+			if shard.Trailer != nil {
+				trailers[hex.EncodeToString(shard.Tag.Bytes()[:DifferentiatorSize])] = *shard.Trailer
+			}
+		}
+		if f.Shards = kept; len(kept) == 0 {
+			delete(i.Files, differentiator)
+		}
+	}
+
+	for _, f := range i.Files {
+		if len(f.Shards) == 0 || !f.Shards[0].Tag.IsSinglePass() {
+			continue
+		}
+		if trailer, ok := trailers[hex.EncodeToString(f.Shards[0].Tag.Bytes()[:DifferentiatorSize])]; ok {
+			f.Trailer = &trailer
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package gopar3
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestInflateWithSinglePass(t *testing.T) {
+	testInflateAndRestore(t, WithSinglePass())
+}
+
+func TestRestoreWithoutTrailer(t *testing.T) {
+	testInflateAndRestoreWithOptions(t, roundTrip{
+		inflate: []InflateOption{WithSinglePass()},
+		prepare: func(t *testing.T, index *Index, _ []string) {
+			sum, err := CastagnoliSum(context.Background(), bytes.NewReader(testFixture()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range index.Files {
+				if file.Trailer == nil || file.Unverified {
+					t.Fatal("trailer was not recovered")
+				}
+				if file.CastagnoliSum != sum {
+					t.Fatal("trailer checksum does not match the source")
+				}
+				file.Trailer = nil
+			}
+			if err = index.Normalize(); err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range index.Files {
+				if !file.Unverified {
+					t.Fatal("file without a trailer is not marked as unverified")
+				}
+			}
+		},
+	})
+}
+
+func TestWideTrailerTag(t *testing.T) {
+	tag := Tag{SourceSize: 1 << 40, ShardQuorum: 300}
+	tag.setShards(400)
+	tag.setCodec(CodecLeopard)
+	data := tag
+	data.ShardOrder, data.ShardBatch = TrailerShardOrder, TrailerShardBatch
+	if NewTagFromBytes(data.Bytes()).IsTrailer() {
+		t.Fatal("data shard of a wide batch was taken for a trailer")
+	}
+	trailer := NewTagFromBytes(newTrailerTagger(tag).Bytes())
+	if !trailer.IsTrailer() || trailer.ShardOrder != WideTrailerShardOrder {
+		t.Fatalf("wide trailer is tagged with order %d", trailer.ShardOrder)
+	}
+	tag.setCodec(CodecReedSolomon)
+	if trailer = NewTagFromBytes(newTrailerTagger(tag).Bytes()); !trailer.IsTrailer() || trailer.ShardOrder != TrailerShardOrder {
+		t.Fatalf("trailer is tagged with order %d", trailer.ShardOrder)
+	}
+}
@@ -0,0 +1,101 @@
+package gopar3
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ByteRange is a span of bytes in a source file.
+type ByteRange struct {
+	Offset int64
+	Size   int64
+}
+
+func (r ByteRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Offset, r.Offset+r.Size)
+}
+
+// overlaps returns true if the range shares bytes with
+// the span from first byte up to, but not including, last.
+func (r ByteRange) overlaps(first, last int64) bool {
+	return r.Offset < last && first < r.Offset+r.Size
+}
+
+// ReadMapfile returns the ranges that a GNU ddrescue mapfile does
+// not report as finished: bad sectors, as well as the areas that
+// were not tried, trimmed, or scraped, because the image holds
+// no data for them.
+func ReadMapfile(r io.Reader) (unreadable []ByteRange, err error) {
+	scanner := bufio.NewScanner(r)
+	status := true // the first line is the status of the rescue
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if status {
+			status = false
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("mapfile line %d: expected position, size, and status", line)
+		}
+		var block ByteRange
+		if block.Offset, err = strconv.ParseInt(fields[0], 0, 64); err != nil {
+			return nil, fmt.Errorf("mapfile line %d: %w", line, err)
+		}
+		if block.Size, err = strconv.ParseInt(fields[1], 0, 64); err != nil {
+			return nil, fmt.Errorf("mapfile line %d: %w", line, err)
+		}
+		if fields[2] == "+" || block.Size == 0 {
+			continue
+		}
+		if n := len(unreadable); n > 0 && unreadable[n-1].Offset+unreadable[n-1].Size == block.Offset {
+			unreadable[n-1].Size += block.Size // merge adjacent blocks
+			continue
+		}
+		unreadable = append(unreadable, block)
+	}
+	return unreadable, scanner.Err()
+}
+
+// MarkUnreadable turns the shards of the source that overlap any
+// of the ranges into erasures up front, so that they are neither
+// loaded nor healed. The best copies of shards are then selected
+// again, like after [Index.ExcludeSources]. Returns the number
+// of marked shards.
+func (i Index) MarkUnreadable(source string, ranges []ByteRange) (marked int) {
+	source = filepath.Clean(source)
+	for _, f := range i.Files {
+		if f.ShardSize == 0 {
+			continue // the file was not normalized
+		}
+		for _, shard := range f.Shards {
+			if shard.Unreadable != nil || filepath.Clean(shard.Source) != source {
+				continue
+			}
+			for _, r := range ranges {
+				if r.overlaps(shard.FirstByte, shard.LastByte) {
+					shard.markUnreadable(r)
+					marked++
+					break
+				}
+			}
+		}
+	}
+	if marked > 0 {
+		i.reselectCopies()
+	}
+	return marked
+}
+
+func (s *Shard) markUnreadable(r ByteRange) {
+	s.Unreadable = &r
+	s.Error = fmt.Sprintf("overlaps unreadable bytes %s", r)
+}
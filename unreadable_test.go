@@ -0,0 +1,170 @@
+package gopar3
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadMapfile(t *testing.T) {
+	ranges, err := ReadMapfile(strings.NewReader(`# Mapfile. Created by GNU ddrescue version 1.27
+# Command line: ddrescue /dev/sdb disk.img disk.map
+# current_pos  current_status  current_pass
+0x00120000     +               1
+#      pos        size  status
+0x00000000  0x00010000  +
+0x00010000  0x00000200  -
+0x00010200  0x00000400  /
+0x00010600  0x00001000  +
+0x00011600  0x00000200  *
+0x00011800  0x00100000  ?
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ByteRange{
+		{Offset: 0x10000, Size: 0x600},
+		{Offset: 0x11600, Size: 0x100200},
+	}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("unexpected ranges: %v", ranges)
+	}
+
+	if _, err = ReadMapfile(strings.NewReader("0 +\n0x10 zz -\n")); err == nil {
+		t.Fatal("accepted a malformed block size")
+	}
+}
+
+func TestMarkUnreadable(t *testing.T) {
+	cases := []struct {
+		name          string
+		duplicate     bool
+		shards        int
+		unrecoverable bool
+	}{
+		{name: "within parity", shards: 3},
+		{name: "beyond parity", shards: 4, unrecoverable: true},
+		{name: "duplicates replace unreadable shards", duplicate: true, shards: 8},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			testInflateAndRestoreWithOptions(t, roundTrip{
+				sources: func(t *testing.T, archive string) []string {
+					if !c.duplicate {
+						return []string{archive}
+					}
+					return []string{archive, copyTestArchive(t, archive, "duplicate.gopar3")}
+				},
+				prepare: func(t *testing.T, index *Index, sources []string) {
+					batch := batchSpans(*index, sources[0], 1)
+					bad := ByteRange{Offset: batch[0].Offset + 1, Size: batch[c.shards-1].Offset - batch[0].Offset}
+					if marked := index.MarkUnreadable(sources[0], []ByteRange{bad}); marked != c.shards {
+						t.Fatalf("marked %d shards instead of %d", marked, c.shards)
+					}
+				},
+				unrecoverable: c.unrecoverable,
+			})
+		})
+	}
+}
+
+func TestMarkUnreadableResolvesDivergentCopies(t *testing.T) {
+	local := &Shard{Source: "local", FirstByte: 0, LastByte: 10, CastagnoliSum: 1}
+	remote := &Shard{Source: "remote", FirstByte: 0, LastByte: 10, CastagnoliSum: 2}
+	f := &File{Quorum: 1, ShardSize: 4, Size: 4, Shards: []*Shard{local, remote}}
+	index := Index{Sources: []string{"local", "remote"}, Files: map[string]*File{"file": f}}
+	index.reselectCopies()
+	if local.Error != errDivergentShard {
+		t.Fatalf("divergent copies were not erased: %q", local.Error)
+	}
+
+	if marked := index.MarkUnreadable("remote", []ByteRange{{Offset: 0, Size: 1}}); marked != 1 {
+		t.Fatalf("marked %d shards instead of one", marked)
+	}
+	if local.Error != "" || f.Divergent != 0 || f.Error != "" {
+		t.Fatalf("the remaining copy was not selected: %q, %d divergent, file error %q", local.Error, f.Divergent, f.Error)
+	}
+}
+
+func TestMarkUnreadableKeepsErrorsOfFilesNotNormalized(t *testing.T) {
+	f := &File{
+		Error:  "trailer does not match",
+		Shards: []*Shard{{Source: "archive.gopar3", FirstByte: 10, LastByte: 20}},
+	}
+	index := Index{Files: map[string]*File{"file": f}}
+	if marked := index.MarkUnreadable("archive.gopar3", []ByteRange{{Offset: 0, Size: 100}}); marked != 0 {
+		t.Fatalf("marked %d shards of a file that was not normalized", marked)
+	}
+	if f.Error != "trailer does not match" {
+		t.Fatal("file error was replaced:", f.Error)
+	}
+}
+
+func TestScanIndexSkipsUnreadable(t *testing.T) {
+	var bad ByteRange
+	testInflateAndRestoreWithOptions(t, roundTrip{
+		sources: func(t *testing.T, archive string) []string {
+			index, err := NewIndex(context.Background(), archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			batch := batchSpans(index, archive, 1)
+			bad = ByteRange{Offset: batch[0].Offset + 1, Size: batch[1].Offset - batch[0].Offset}
+			b, err := os.ReadFile(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// a rescued image holds zeros in place of two shards
+			clear(b[bad.Offset : bad.Offset+bad.Size])
+			if err = os.WriteFile(archive, b, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return []string{archive}
+		},
+		scan: func(sources []string) []IndexOption {
+			return []IndexOption{WithUnreadable(sources[0], bad)}
+		},
+		prepare: func(t *testing.T, index *Index, _ []string) {
+			if len(index.Skipped) != 1 || index.Skipped[0].ByteRange != bad {
+				t.Fatalf("unexpected skipped regions: %v", index.Skipped)
+			}
+			for _, file := range index.Files {
+				for _, shard := range file.Shards {
+					if shard.Unreadable == nil && bad.overlaps(shard.FirstByte, shard.LastByte) {
+						t.Fatalf("decoded shard %d-%d overlapping unreadable bytes", shard.FirstByte, shard.LastByte)
+					}
+				}
+			}
+		},
+	})
+}
+
+// batchSpans returns the spans of the shards of the batch
+// found in the source in the order of their shards.
+func batchSpans(index Index, source string, batch uint16) (spans []ByteRange) {
+	for _, file := range index.Files {
+		for _, shard := range file.Shards {
+			if shard.Source == source && shard.Tag.ShardBatch == batch {
+				spans = append(spans, ByteRange{Offset: shard.FirstByte, Size: shard.LastByte - shard.FirstByte})
+			}
+		}
+	}
+	return spans
+}
+
+// copyTestArchive writes a copy of the archive next to it.
+func copyTestArchive(t *testing.T, archive, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicate := filepath.Join(filepath.Dir(archive), name)
+	if err = os.WriteFile(duplicate, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return duplicate
+}
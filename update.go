@@ -0,0 +1,291 @@
+package gopar3
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/dkotik/gopar3/telomeres"
+)
+
+// previousArchive holds the batches of an earlier version of an
+// archive that can be reused in the updated archive, because their
+// data did not change.
+//
+// Tags of sources inflated in a single pass separate the identity
+// of a file, a random number, from its version, the checksum
+// recorded in the [Trailer]. So, shards of unchanged batches remain
+// valid for the next version, as long as the size of the source
+// is the same. Otherwise, only their parity is reused.
+type previousArchive struct {
+	path      string
+	tag       Tag
+	marks     telomeres.Marks
+	framing   telomeres.Framing
+	innerCode bool
+	batches   map[uint16]previousBatch
+}
+
+// previousBatch is a span of intact shards of one batch written
+// back to back. Sums are SHA-256 digests of the data carried
+// by its data shards, which do not depend on the tags.
+type previousBatch struct {
+	firstByte int64
+	lastByte  int64
+	tag       Tag
+	shards    []*Shard
+	sums      [][sha256.Size]byte
+}
+
+// Update inflates a new version of the source into an existing
+// archive created by [Inflate]. Quorum, parity, shard size, and
+// telomeres are taken from the archive. Batches, whose data did
+// not change, are copied from the archive instead of encoding.
+// If the size of the source changed, their tags are written again
+// and only the parity shards are taken from the archive.
+// Updated archive is inflated in a single pass and keeps
+// the identity of the previous version, if it was also inflated
+// in a single pass. Otherwise, the tags of every batch are
+// written again.
+func Update(ctx context.Context, archive, source string, withOptions ...InflateOption) error {
+	index, err := NewIndex(ctx, archive)
+	if err != nil {
+		return err
+	}
+	var file *File
+	for _, candidate := range index.Files {
+		if file == nil || len(candidate.Shards) > len(file.Shards) {
+			file = candidate
+		}
+	}
+	if file.ShardSize < 1 || len(file.Shards) == 0 {
+		return errors.New("archive has no recoverable shards")
+	}
+
+	previous, shards, err := newPreviousArchive(ctx, archive, file)
+	if err != nil {
+		return err
+	}
+	if shards < int(file.Quorum) {
+		return errors.New("archive does not have enough shards in a batch")
+	}
+	options := []InflateOption{
+		WithTelomereMarks(previous.marks.Mark, previous.marks.Escape),
+		WithCodec(file.Codec),
+	}
+	if previous.framing == telomeres.FramingStuffing {
+		options = append(options, WithByteStuffing())
+	}
+	if previous.innerCode {
+		options = append(options, WithInnerCode())
+	}
+	for _, shard := range file.Shards {
+		if len(shard.SubChunks) > 1 {
+			options = append(options, WithSyncMarkers(int(shard.SubChunks[0].Size)))
+			break
+		}
+	}
+	options = append(options, withOptions...)
+	options = append(options, WithSinglePass(), withPreviousArchive(previous))
+	return Inflate(
+		ctx,
+		archive,
+		source,
+		file.Quorum,
+		uint16(shards-int(file.Quorum)),
+		int(file.ShardSize),
+		options...,
+	)
+}
+
+// newPreviousArchive collects the batches of the file that can be
+// reused. Returns the number of shards in each batch.
+func newPreviousArchive(ctx context.Context, path string, f *File) (previous *previousArchive, shards int, err error) {
+	ordered := slices.Clone(f.Shards)
+	slices.SortFunc(ordered, func(a, b *Shard) int {
+		return cmp.Compare(a.FirstByte, b.FirstByte)
+	})
+	shards = f.shardCount()
+
+	first := ordered[0]
+	previous = &previousArchive{
+		path:      path,
+		marks:     telomeres.DefaultMarks,
+		framing:   first.Framing,
+		innerCode: first.InnerCode,
+		batches:   make(map[uint16]previousBatch),
+	}
+	if first.Telomeres != nil {
+		previous.marks = *first.Telomeres
+	}
+	if first.Tag.IsSinglePass() {
+		previous.tag = first.Tag
+		previous.tag.ShardOrder = 0
+		previous.tag.ShardBatch = 0
+	}
+
+	archive, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		err = errors.Join(err, archive.Close())
+	}()
+
+	ambiguous := make(map[uint16]bool)
+	buffer := make([]byte, 0, f.ShardSize)
+	for i := 0; i+shards <= len(ordered); i++ {
+		run := ordered[i : i+shards]
+		if !isCopyableBatch(run, f.ShardSize) {
+			continue
+		}
+		batch := run[0].Tag.ShardBatch
+		if _, ok := previous.batches[batch]; ok {
+			ambiguous[batch] = true // counter wrapped around
+		}
+		sums, err := dataSums(ctx, archive, run[:f.Quorum], buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, 0, ctx.Err()
+			}
+			continue // unreadable batches are encoded again
+		}
+		previous.batches[batch] = previousBatch{
+			firstByte: run[0].FirstByte,
+			lastByte:  run[len(run)-1].LastByte,
+			tag:       run[0].Tag,
+			shards:    slices.Clone(run),
+			sums:      sums,
+		}
+		i += shards - 1
+	}
+	for batch := range ambiguous {
+		delete(previous.batches, batch)
+	}
+	return previous, shards, nil
+}
+
+// dataSums returns SHA-256 digests of the data carried by the shards.
+func dataSums(ctx context.Context, r io.ReaderAt, shards []*Shard, buffer []byte) ([][sha256.Size]byte, error) {
+	sums := make([][sha256.Size]byte, len(shards))
+	for order, shard := range shards {
+		data, err := shard.load(ctx, r, buffer)
+		if err != nil {
+			return nil, err
+		}
+		sums[order] = sha256.Sum256(data)
+	}
+	return sums, nil
+}
+
+// isCopyableBatch returns true if the shards form a complete batch
+// in order, separated by nothing but telomeres.
+func isCopyableBatch(run []*Shard, shardSize int64) bool {
+	for order, shard := range run {
+		if shard.Error != "" ||
+			shard.Realigned != nil ||
+			slices.ContainsFunc(shard.SubChunks, isDamaged) ||
+			shard.Tag.IsTrailer() ||
+			shard.Tag.ShardBatch != run[0].Tag.ShardBatch ||
+			int(shard.Tag.ShardOrder) != order ||
+			shard.payloadSize() != shardSize {
+			return false
+		}
+		if order > 0 && shard.FirstByte-run[order-1].LastByte != telomereCount {
+			return false
+		}
+	}
+	return true
+}
+
+// unchanged returns the previous version of the batch, if
+// the data shards match its digests. The tags are not compared.
+func (p *previousArchive) unchanged(batch int, shards [][]byte) (previousBatch, bool) {
+	if p == nil {
+		return previousBatch{}, false
+	}
+	previous, ok := p.batches[uint16(batch)]
+	if !ok || len(previous.sums) != len(shards) {
+		return previousBatch{}, false
+	}
+	for order, shard := range shards {
+		if sha256.Sum256(shard) != previous.sums[order] {
+			return previousBatch{}, false
+		}
+	}
+	return previous, true
+}
+
+// isVerbatim returns true if the encoded shards of the batch can be
+// copied as they are, because they carry the same tags as the shards
+// of the batch in the updated archive would.
+func (b previousBatch) isVerbatim(tag Tag) bool {
+	tag.ShardBatch = b.tag.ShardBatch
+	return b.tag == tag
+}
+
+// loadParity reads the parity shards of the batch from the previous
+// version of the archive into the empty buffers that follow the data
+// shards. The buffers are left empty, if one of the shards fails.
+func (b previousBatch) loadParity(ctx context.Context, r io.ReaderAt, shards [][]byte) (err error) {
+	parity := shards[len(b.sums):]
+	defer func() {
+		if err != nil {
+			for order := range parity {
+				parity[order] = parity[order][:0]
+			}
+		}
+	}()
+	for order, shard := range b.shards[len(b.sums):] {
+		data, err := shard.load(ctx, r, nil)
+		if err != nil {
+			return err
+		}
+		if len(data) != len(shards[0]) {
+			return io.ErrUnexpectedEOF
+		}
+		parity[order] = append(parity[order][:0], data...)
+	}
+	return nil
+}
+
+func withPreviousArchive(p *previousArchive) InflateOption {
+	return func(o *inflateOptions) error {
+		o.previous = p
+		return nil
+	}
+}
+
+// copyBatch writes the encoded shards of an unchanged batch
+// from the previous version of the archive as they are
+// and ends them with telomeres, like [writer] would.
+func copyBatch(
+	w io.Writer,
+	encoder *telomeres.Encoder,
+	tagger Tagger,
+	previous io.ReaderAt,
+	batch *previousBatch,
+	shards int,
+) (err error) {
+	size := batch.lastByte - batch.firstByte
+	n, err := io.Copy(w, io.NewSectionReader(previous, batch.firstByte, size))
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return io.ErrUnexpectedEOF
+	}
+	if _, err = encoder.Cut(); err != nil {
+		return err
+	}
+	for range shards {
+		if err = tagger.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package gopar3
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	original := make([]byte, 64*1024+100)
+	rand.New(rand.NewSource(1)).Read(original)
+	changed := bytes.Clone(original)
+	changed[len(changed)/2] ^= 0xff
+	batches := (len(original) + 4*256 - 1) / (4 * 256)
+
+	cases := []struct {
+		name     string
+		options  []InflateOption
+		source   []byte
+		copied   int
+		identity bool
+	}{
+		{name: "unchanged", options: []InflateOption{WithSinglePass()}, source: original, copied: batches, identity: true},
+		{name: "one batch changed", options: []InflateOption{WithSinglePass()}, source: changed, copied: batches - 1, identity: true},
+		{name: "sync markers", options: []InflateOption{WithSinglePass(), WithSyncMarkers(100)}, source: changed, copied: batches - 1, identity: true},
+		{name: "stuffing", options: []InflateOption{WithSinglePass(), WithByteStuffing()}, source: changed, copied: batches - 1, identity: true},
+		{name: "size shrunk", options: []InflateOption{WithSinglePass()}, source: original[:len(original)-1], copied: batches - 1, identity: true},
+		{name: "size grown", options: []InflateOption{WithSinglePass()}, source: append(bytes.Clone(original), 1), copied: batches - 1, identity: true},
+		{name: "two passes", source: changed, copied: batches - 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			directory := t.TempDir()
+			source := filepath.Join(directory, "source.bin")
+			archive := filepath.Join(directory, "archive.gopar3")
+			if err := os.WriteFile(source, original, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := Inflate(ctx, archive, source, 4, 2, 256, c.options...); err != nil {
+				t.Fatal(err)
+			}
+			before := restoreArchive(t, ctx, archive)
+			if !bytes.Equal(before.data, original) {
+				t.Fatal("archive was not restored before the update")
+			}
+
+			if err := os.WriteFile(source, c.source, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var final Progress
+			progress := WithInflateProgress(func(p Progress) {
+				final = p
+			})
+			if err := Update(ctx, archive, source, progress); err != nil {
+				t.Fatal(err)
+			}
+			if final.BatchesCopied != c.copied {
+				t.Errorf("copied %d batches instead of %d", final.BatchesCopied, c.copied)
+			}
+
+			after := restoreArchive(t, ctx, archive)
+			if !bytes.Equal(after.data, c.source) {
+				t.Fatal("updated archive does not restore the new version of the source")
+			}
+			if after.file.Unverified {
+				t.Fatal("updated archive has no trailer")
+			}
+			if identity := after.file.Shards[0].Tag.SourceCRC == before.file.Shards[0].Tag.SourceCRC; identity != c.identity {
+				t.Errorf("identity was kept: %v, expected: %v", identity, c.identity)
+			}
+		})
+	}
+}
+
+type restoredArchive struct {
+	file *File
+	data []byte
+}
+
+func restoreArchive(t *testing.T, ctx context.Context, archive string) (restored restoredArchive) {
+	t.Helper()
+	index, err := NewIndex(ctx, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Files) != 1 {
+		t.Fatalf("archive contains %d files instead of one", len(index.Files))
+	}
+	for _, file := range index.Files {
+		b := &bytes.Buffer{}
+		if err = Restore(ctx, b, file); err != nil {
+			t.Fatal(err)
+		}
+		restored = restoredArchive{file: file, data: b.Bytes()}
+	}
+	return restored
+}
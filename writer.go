@@ -1,10 +1,12 @@
 package gopar3
 
 import (
+	"encoding/binary"
 	"hash"
 	"hash/crc32"
 	"io"
 
+	"github.com/dkotik/gopar3/ecc"
 	"github.com/dkotik/gopar3/telomeres"
 )
 
@@ -12,16 +14,28 @@ type writer struct {
 	encoder *telomeres.Encoder
 	tagger  Tagger
 	crc     hash.Hash32
+	header  []byte
+	// encoded holds the codewords of the shard,
+	// if it is protected by the inner code.
+	encoded   []byte
+	innerCode bool
 }
 
 func NewWriter(w *telomeres.Encoder, t Tagger) (io.Writer, error) {
+	return newWriter(w, t, false)
+}
+
+// newWriter protects whole shards with [ecc] codewords,
+// if innerCode is set. See [WithInnerCode].
+func newWriter(w *telomeres.Encoder, t Tagger, innerCode bool) (io.Writer, error) {
 	if _, err := w.Cut(); err != nil {
 		return nil, err
 	}
 	return &writer{
-		encoder: w,
-		tagger:  t,
-		crc:     crc32.New(castagnoliTable),
+		encoder:   w,
+		tagger:    t,
+		crc:       crc32.New(castagnoliTable),
+		innerCode: innerCode,
 	}, nil
 }
 
@@ -29,9 +43,10 @@ func NewWriter(w *telomeres.Encoder, t Tagger) (io.Writer, error) {
 // to the [telomeres.Encoder]. Ends with a telomere sequence to designate
 // the end of the shard.
 func (w *writer) Write(b []byte) (n int, err error) {
-	{ // write checksum
+	tag := w.tagger.Bytes()
+	{ // write checksum and tag
 		w.crc.Reset()
-		_, err = w.crc.Write(w.tagger.Bytes())
+		_, err = w.crc.Write(tag)
 		if err != nil {
 			return 0, err
 		}
@@ -39,21 +54,16 @@ func (w *writer) Write(b []byte) (n int, err error) {
 		if err != nil {
 			return 0, err
 		}
-		n, err = w.encoder.Write(w.crc.Sum(nil))
-		if err != nil {
-			return 0, err
-		}
-		if n != TagBytesForCRC {
-			return 0, io.ErrShortWrite
+		w.header = binary.BigEndian.AppendUint32(w.header[:0], w.crc.Sum32())
+		w.header = append(w.header, tag...)
+		if w.innerCode {
+			return w.writeEncoded(b)
 		}
-	}
-
-	{ // write tag
-		n, err = w.encoder.Write(w.tagger.Bytes())
+		n, err = w.encoder.Write(w.header)
 		if err != nil {
 			return 0, err
 		}
-		if n != TagSize {
+		if n != len(w.header) {
 			return 0, io.ErrShortWrite
 		}
 	}
@@ -70,3 +80,21 @@ func (w *writer) Write(b []byte) (n int, err error) {
 	}
 	return n, w.tagger.Next()
 }
+
+// writeEncoded writes the header and the given bytes
+// as [ecc] codewords.
+func (w *writer) writeEncoded(b []byte) (n int, err error) {
+	w.header = append(w.header, b...) // reused by the next shard
+	w.encoded = ecc.Encode(w.encoded[:0], w.header)
+	n, err = w.encoder.Write(w.encoded)
+	if err != nil {
+		return 0, err
+	}
+	if n != len(w.encoded) {
+		return 0, io.ErrShortWrite
+	}
+	if _, err = w.encoder.Cut(); err != nil {
+		return 0, err
+	}
+	return len(b), w.tagger.Next()
+}
@@ -2,8 +2,10 @@ package gopar3
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"hash/crc32"
+	"io"
 	"testing"
 
 	"github.com/dkotik/gopar3/telomeres"
@@ -66,3 +68,30 @@ func TestWriteShardsWithTagAndChecksum(t *testing.T) {
 	t.Logf("%q", b.String())
 	// t.Fatal("check result")
 }
+
+func TestReadingPastLastShard(t *testing.T) {
+	b := &bytes.Buffer{}
+	tlm, err := telomeres.NewEncoder(b, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWriter(tlm, NewSequentialTagger(Tag{}, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("shard")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader("test", bytes.NewReader(b.Bytes()))
+	shard, err := r.NextShard(context.Background(), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shard.Error != "" {
+		t.Fatal(shard.Error)
+	}
+	if _, err = r.NextShard(context.Background(), io.Discard); err != io.EOF {
+		t.Fatalf("reading past the last shard returned %v instead of io.EOF", err)
+	}
+}